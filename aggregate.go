@@ -0,0 +1,186 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+)
+
+// The aggregates computed over the matches of a search, grouped by kind, in
+// the order they were requested. See SearchAggregate.
+type Aggregates struct {
+	Stats      []StatsAggregate
+	Range      []RangeAggregate
+	Distance   []DistanceAggregate
+	TimeSeries []TimeSeriesAggregate
+}
+
+// Summary statistics of a numeric field, as requested with "<field>:stats".
+type StatsAggregate struct {
+	FieldName    string
+	ValueCount   uint64
+	Min          float64
+	Max          float64
+	Mean         float64
+	Sum          float64
+	SumOfSquares float64
+	Variance     float64
+	StdDev       float64
+}
+
+// Counts of the values of a numeric field falling in each of a set of ranges,
+// as requested with "<field>:range:<min>~<max>:...".
+type RangeAggregate struct {
+	FieldName  string
+	ValueCount uint64
+	Buckets    []RangeBucket
+}
+
+// Counts of the values of a geographic field falling in each of a set of
+// distance ranges from a point, as requested with
+// "<field>:distance:<min>~<max>:...". The point is the one given in the
+// search query's NEAR clause.
+type DistanceAggregate struct {
+	FieldName  string
+	ValueCount uint64
+	Buckets    []RangeBucket
+}
+
+// Counts of the values of a date field falling in each interval, as requested
+// with "<field>:time_series:<interval>", where the interval is one of year,
+// quarter, month, week, day or hour.
+type TimeSeriesAggregate struct {
+	FieldName  string
+	ValueCount uint64
+	Interval   string
+	Buckets    []TimeBucket
+}
+
+// A range of a RangeAggregate or DistanceAggregate, and the number of values
+// within it. A range that is open at one end, given as * in the request, has
+// a Min of negative infinity or a Max of positive infinity.
+type RangeBucket struct {
+	Min   float64
+	Max   float64
+	Count uint64
+}
+
+// An interval of a TimeSeriesAggregate, named as in "2014-07" or "2014-07-31",
+// and the number of values within it.
+type TimeBucket struct {
+	Bucket string `json:"bucket"`
+	Count  uint64 `json:"count"`
+}
+
+// Search a collection as Search does, also computing the aggregates described
+// by aggregate over all of the matches, not just the page returned. The
+// aggregates are given as a comma separated list such as
+// "value.price:stats,value.price:range:*~10:10~*,value.date:time_series:day"
+// and are returned in the Aggregates of the results.
+func (c *Client) SearchAggregate(collection, query, aggregate string, limit, offset int) (*SearchResults, error) {
+	queryVariables := url.Values{
+		"query":     []string{query},
+		"aggregate": []string{aggregate},
+		"limit":     []string{strconv.Itoa(limit)},
+		"offset":    []string{strconv.Itoa(offset)},
+	}
+
+	trailingUri := buildURI(collection) + "?" + queryVariables.Encode()
+
+	return c.doSearch(trailingUri)
+}
+
+// Decodes the aggregates array of a search response, sorting each aggregate
+// by its kind. Aggregates of kinds the client does not know are skipped.
+func (a *Aggregates) UnmarshalJSON(data []byte) error {
+	var raw []struct {
+		Kind       string `json:"aggregate_kind"`
+		FieldName  string `json:"field_name"`
+		ValueCount uint64 `json:"value_count"`
+		Interval   string `json:"interval"`
+		Statistics struct {
+			Min          float64 `json:"min"`
+			Max          float64 `json:"max"`
+			Mean         float64 `json:"mean"`
+			Sum          float64 `json:"sum"`
+			SumOfSquares float64 `json:"sum_of_squares"`
+			Variance     float64 `json:"variance"`
+			StdDev       float64 `json:"std_dev"`
+		} `json:"statistics"`
+		Buckets json.RawMessage `json:"buckets"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*a = Aggregates{}
+	for _, r := range raw {
+		switch r.Kind {
+		case "stats":
+			s := r.Statistics
+			a.Stats = append(a.Stats, StatsAggregate{
+				FieldName:    r.FieldName,
+				ValueCount:   r.ValueCount,
+				Min:          s.Min,
+				Max:          s.Max,
+				Mean:         s.Mean,
+				Sum:          s.Sum,
+				SumOfSquares: s.SumOfSquares,
+				Variance:     s.Variance,
+				StdDev:       s.StdDev,
+			})
+		case "range", "distance":
+			var buckets []RangeBucket
+			if err := unmarshalBuckets(r.Buckets, &buckets); err != nil {
+				return fmt.Errorf("%s aggregate of %s: %w", r.Kind, r.FieldName, err)
+			}
+			if r.Kind == "range" {
+				a.Range = append(a.Range, RangeAggregate{r.FieldName, r.ValueCount, buckets})
+			} else {
+				a.Distance = append(a.Distance, DistanceAggregate{r.FieldName, r.ValueCount, buckets})
+			}
+		case "time_series":
+			var buckets []TimeBucket
+			if err := unmarshalBuckets(r.Buckets, &buckets); err != nil {
+				return fmt.Errorf("%s aggregate of %s: %w", r.Kind, r.FieldName, err)
+			}
+			a.TimeSeries = append(a.TimeSeries, TimeSeriesAggregate{r.FieldName, r.ValueCount, r.Interval, buckets})
+		}
+	}
+
+	return nil
+}
+
+// Decodes the buckets of an aggregate, which may be absent.
+func unmarshalBuckets(data json.RawMessage, buckets interface{}) error {
+	if len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, buckets)
+}
+
+// Decodes a range bucket, leaving the infinite ends of an open range as
+// infinities.
+func (b *RangeBucket) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Min   *float64 `json:"min"`
+		Max   *float64 `json:"max"`
+		Count uint64   `json:"count"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*b = RangeBucket{Min: math.Inf(-1), Max: math.Inf(1), Count: raw.Count}
+	if raw.Min != nil {
+		b.Min = *raw.Min
+	}
+	if raw.Max != nil {
+		b.Max = *raw.Max
+	}
+	return nil
+}
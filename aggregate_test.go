@@ -0,0 +1,55 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"testing"
+)
+
+func TestSearchAggregate(t *testing.T) {
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		if agg := r.URL.Query().Get("aggregate"); agg != "value.price:stats,value.price:range:*~10:10~*" {
+			t.Errorf("unexpected aggregate %q", agg)
+		}
+		fmt.Fprint(w, `{"count":0,"total_count":3,"results":[],"aggregates":[
+			{"aggregate_kind":"stats","field_name":"value.price","value_count":3,
+			 "statistics":{"min":5,"max":20,"mean":10,"sum":30,"sum_of_squares":350,"variance":16.67,"std_dev":4.08}},
+			{"aggregate_kind":"range","field_name":"value.price","value_count":3,
+			 "buckets":[{"max":10,"count":2},{"min":10,"count":1}]},
+			{"aggregate_kind":"distance","field_name":"value.location","value_count":1,
+			 "buckets":[{"min":0,"max":1,"count":1}]},
+			{"aggregate_kind":"time_series","field_name":"value.date","interval":"month","value_count":3,
+			 "buckets":[{"bucket":"2014-07","count":3}]},
+			{"aggregate_kind":"unknown","field_name":"value.x"}]}`)
+	}))
+
+	results, err := c.SearchAggregate("items", "*", "value.price:stats,value.price:range:*~10:10~*", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := results.Aggregates
+	if len(a.Stats) != 1 || a.Stats[0].FieldName != "value.price" || a.Stats[0].Mean != 10 || a.Stats[0].SumOfSquares != 350 {
+		t.Errorf("unexpected stats %+v", a.Stats)
+	}
+
+	if len(a.Range) != 1 || len(a.Range[0].Buckets) != 2 {
+		t.Fatalf("unexpected ranges %+v", a.Range)
+	}
+	if b := a.Range[0].Buckets[0]; !math.IsInf(b.Min, -1) || b.Max != 10 || b.Count != 2 {
+		t.Errorf("unexpected open low bucket %+v", b)
+	}
+	if b := a.Range[0].Buckets[1]; b.Min != 10 || !math.IsInf(b.Max, 1) || b.Count != 1 {
+		t.Errorf("unexpected open high bucket %+v", b)
+	}
+
+	if len(a.Distance) != 1 || a.Distance[0].Buckets[0].Max != 1 {
+		t.Errorf("unexpected distances %+v", a.Distance)
+	}
+	if len(a.TimeSeries) != 1 || a.TimeSeries[0].Interval != "month" || a.TimeSeries[0].Buckets[0] != (TimeBucket{"2014-07", 3}) {
+		t.Errorf("unexpected time series %+v", a.TimeSeries)
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// Adds credentials to a request before it is sent, such as an Authorization
+// header or a signature.
+type Authenticator interface {
+	// Called once with each request, after all of its other headers have
+	// been set. It must be safe to call from several goroutines at once.
+	Apply(req *http.Request)
+}
+
+// Authorize requests with authenticator in place of the auth token, allowing
+// schemes other than the basic auth Orchestrate expects, such as the bearer
+// tokens of a gateway in front of it, or credentials that rotate. A retried
+// request keeps the credentials it was first given. Setting authenticator to
+// nil restores the default of sending the auth token with basic auth.
+// Clients derived with WithAuthToken go back to that default, using their
+// own token. Unlike the auth token, the authenticator is applied after the
+// headers given to WithHeaders, so that it can see or sign all of them.
+//
+// This should be called before the Client is shared between goroutines.
+func (c *Client) SetAuthenticator(authenticator Authenticator) {
+	c.authenticator = authenticator
+}
+
+// The key under which a request's context holds the credentials its
+// authenticator added.
+type credentialsKey struct{}
+
+// Applies the client's authenticator to a request, returning the request to
+// send. The values of the headers the authenticator added or changed are
+// recorded in the request's context, so that newError can scrub them from
+// the messages of responses that echo them.
+func (c *Client) applyAuthenticator(req *http.Request) *http.Request {
+	before := req.Header.Clone()
+	c.authenticator.Apply(req)
+
+	var secrets []string
+	for name, values := range req.Header {
+		if strings.Join(values, "\n") == strings.Join(before[name], "\n") {
+			continue
+		}
+		for _, value := range values {
+			if value == "" {
+				continue
+			}
+			secrets = append(secrets, value)
+			// Credentials are often given after a scheme, as in
+			// "Bearer <token>", and may be echoed without it.
+			if i := strings.IndexByte(value, ' '); i >= 0 && strings.TrimSpace(value[i+1:]) != "" {
+				secrets = append(secrets, strings.TrimSpace(value[i+1:]))
+			}
+		}
+	}
+
+	if len(secrets) == 0 {
+		return req
+	}
+	return req.WithContext(context.WithValue(req.Context(), credentialsKey{}, secrets))
+}
+
+// Returns the credentials recorded by applyAuthenticator for a request.
+func requestCredentials(req *http.Request) []string {
+	secrets, _ := req.Context().Value(credentialsKey{}).([]string)
+	return secrets
+}
@@ -0,0 +1,44 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+import (
+	"net/http"
+	"testing"
+)
+
+type bearerAuth string
+
+func (token bearerAuth) Apply(req *http.Request) {
+	req.Header.Set("Authorization", "Bearer "+string(token))
+}
+
+func TestSetAuthenticator(t *testing.T) {
+	var authorization string
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		w.WriteHeader(200)
+	}))
+
+	if err := c.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	if authorization != "Basic dG9rZW46" {
+		t.Errorf("expected basic auth by default, got %q", authorization)
+	}
+
+	c.SetAuthenticator(bearerAuth("secret"))
+	if err := c.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	if authorization != "Bearer secret" {
+		t.Errorf("expected the authenticator to be applied, got %q", authorization)
+	}
+
+	if err := c.WithAuthToken("other").Ping(); err != nil {
+		t.Fatal(err)
+	}
+	if authorization != "Basic b3RoZXI6" {
+		t.Errorf("expected WithAuthToken to restore basic auth, got %q", authorization)
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+import (
+	"sync"
+)
+
+// Queues writes to many collection-key pairs and performs them concurrently,
+// for loading large amounts of data. Operations are queued with Put, Delete
+// and PutEvent and performed by Run. A BulkWriter is not safe for use by
+// several goroutines at once.
+type BulkWriter struct {
+	client      *Client
+	concurrency int
+	ops         []bulkOp
+	progress    func(done, total int)
+}
+
+// A queued write, to the collection-key pair of path.
+type bulkOp struct {
+	path  Path
+	write func(c *Client) error
+}
+
+// Returns a BulkWriter that performs its operations through the client,
+// making at most concurrency requests at once.
+func (c *Client) NewBulkWriter(concurrency int) *BulkWriter {
+	return &BulkWriter{client: c, concurrency: concurrency}
+}
+
+// Queue the storing of a value to a collection-key pair, as Put does.
+func (b *BulkWriter) Put(collection, key string, value interface{}) {
+	b.queue(collection, key, func(c *Client) error {
+		_, err := c.Put(collection, key, value)
+		return err
+	})
+}
+
+// Queue the deletion of the value of a collection-key pair, as Delete does.
+func (b *BulkWriter) Delete(collection, key string) {
+	b.queue(collection, key, func(c *Client) error {
+		return c.Delete(collection, key)
+	})
+}
+
+// Queue the storing of an event of the specified type to a collection-key
+// pair, as PutEvent does.
+func (b *BulkWriter) PutEvent(collection, key, kind string, value interface{}) {
+	b.queue(collection, key, func(c *Client) error {
+		return c.PutEvent(collection, key, kind, value)
+	})
+}
+
+// Adds an operation on a collection-key pair to the queue.
+func (b *BulkWriter) queue(collection, key string, write func(c *Client) error) {
+	b.ops = append(b.ops, bulkOp{path: Path{Collection: collection, Key: key}, write: write})
+}
+
+// Call fn after each operation Run performs, with the number performed so far
+// and the number queued, so that the progress of a long load can be
+// reported. It is called from the goroutines performing the operations, one
+// call at a time.
+func (b *BulkWriter) OnProgress(fn func(done, total int)) {
+	b.progress = fn
+}
+
+// Returns the number of operations queued.
+func (b *BulkWriter) Len() int {
+	return len(b.ops)
+}
+
+// Perform every queued operation and empty the queue. Operations on the same
+// collection-key pair are performed one after another, in the order they
+// were queued; those on different pairs are performed concurrently, in no
+// particular order. A failure does not stop the other operations. The
+// returned slice holds the error for each operation at the index of the order
+// it was queued in, nil for those that succeeded; it is nil if every
+// operation succeeded.
+func (b *BulkWriter) Run() []error {
+	ops := b.ops
+	b.ops = nil
+
+	errs := make([]error, len(ops))
+	var mu sync.Mutex
+	done := 0
+
+	key := func(i int) string {
+		return buildURI(ops[i].path.Collection, ops[i].path.Key)
+	}
+	parallelByKey(len(ops), b.concurrency, key, func(i int) {
+		errs[i] = ops[i].write(b.client)
+
+		if b.progress != nil {
+			mu.Lock()
+			done++
+			b.progress(done, len(ops))
+			mu.Unlock()
+		}
+	})
+
+	for _, err := range errs {
+		if err != nil {
+			return errs
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestBulkWriter(t *testing.T) {
+	var mu sync.Mutex
+	var requests []string
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/fail"):
+			w.WriteHeader(500)
+			w.Write([]byte(`{"message":"failed"}`))
+		case r.Method == "DELETE":
+			w.WriteHeader(204)
+		case strings.Contains(r.URL.Path, "/events/"):
+			w.Header().Set("Location", r.URL.Path+"/1400000000000/1")
+			w.WriteHeader(201)
+		default:
+			w.Header().Set("Location", r.URL.Path+"/refs/1")
+			w.WriteHeader(201)
+		}
+	}))
+
+	b := c.NewBulkWriter(4)
+	b.Put("users", "a", map[string]int{"n": 1})
+	b.PutEvent("users", "a", "login", map[string]int{"n": 2})
+	b.Delete("users", "a")
+	b.Put("users", "fail", map[string]int{"n": 3})
+	b.Put("users", "b", map[string]int{"n": 4})
+
+	var progress []int
+	b.OnProgress(func(done, total int) {
+		if total != 5 {
+			t.Errorf("expected a total of 5, got %d", total)
+		}
+		progress = append(progress, done)
+	})
+
+	if b.Len() != 5 {
+		t.Fatalf("expected 5 queued operations, got %d", b.Len())
+	}
+
+	errs := b.Run()
+	if len(errs) != 5 || errs[3] == nil || errs[0] != nil || errs[4] != nil {
+		t.Errorf("expected only the fourth operation to fail, got %v", errs)
+	}
+	if len(progress) != 5 || progress[4] != 5 {
+		t.Errorf("unexpected progress %v", progress)
+	}
+
+	var forA []string
+	for _, request := range requests {
+		if strings.Contains(request, " /v0/users/a") {
+			forA = append(forA, request)
+		}
+	}
+	expected := "[PUT /v0/users/a PUT /v0/users/a/events/login DELETE /v0/users/a]"
+	if fmt.Sprint(forA) != expected {
+		t.Errorf("expected the operations on a in order, got %v", forA)
+	}
+
+	if b.Len() != 0 || b.Run() != nil {
+		t.Error("expected Run to empty the queue")
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+)
+
+// Cache up to size of the most recently read values in memory. While a value
+// is cached, Get and GetPath (without a ref) revalidate it with a conditional
+// request that carries its ref, and return the cached copy without
+// transferring it again if it has not changed since. The least recently used
+// value is evicted once the cache is full. Setting size to zero, the default,
+// disables caching.
+//
+// The cache is shared with every Client derived from this one afterwards
+// with WithAuthToken or WithHeaders. A cached value is only ever returned
+// after the server has confirmed the requesting client's view of the key
+// still has the cached ref.
+//
+// This should be called before the Client is shared between goroutines.
+func (c *Client) EnableCache(size int) {
+	if size <= 0 {
+		c.cache = nil
+		return
+	}
+	c.cache = newValueCache(size)
+}
+
+// Get the value at the path, revalidating any cached copy.
+func (c *Client) getCached(path *Path) (*KVResult, error) {
+	key := Path{Collection: path.Collection, Key: path.Key}
+
+	cached, ok := c.cache.get(key)
+	if !ok {
+		result, _, err := c.doGet(path, nil)
+		if err != nil {
+			return nil, err
+		}
+		c.cache.add(key, *result)
+		return result, nil
+	}
+
+	headers := map[string]string{
+		"If-None-Match": quoteRef(cached.Path.Ref),
+	}
+
+	result, modified, err := c.doGet(path, headers)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			c.cache.remove(key)
+		}
+		return nil, err
+	}
+
+	if !modified {
+		path.Ref = cached.Path.Ref
+		return &cached, nil
+	}
+
+	c.cache.add(key, *result)
+	return result, nil
+}
+
+// A fixed size, least recently used cache of values by collection-key pair.
+type valueCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[Path]*list.Element
+}
+
+// An entry in a valueCache.
+type cacheEntry struct {
+	key    Path
+	result KVResult
+}
+
+func newValueCache(size int) *valueCache {
+	return &valueCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[Path]*list.Element),
+	}
+}
+
+// Returns a copy of the cached result for a key, if there is one.
+func (vc *valueCache) get(key Path) (KVResult, bool) {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+
+	element, ok := vc.entries[key]
+	if !ok {
+		return KVResult{}, false
+	}
+
+	vc.order.MoveToFront(element)
+	result := element.Value.(*cacheEntry).result
+	result.RawValue = result.Raw()
+	return result, true
+}
+
+// Caches a copy of the result for a key, evicting the least recently used
+// entry if the cache is full.
+func (vc *valueCache) add(key Path, result KVResult) {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+
+	result.RawValue = result.Raw()
+
+	if element, ok := vc.entries[key]; ok {
+		element.Value.(*cacheEntry).result = result
+		vc.order.MoveToFront(element)
+		return
+	}
+
+	vc.entries[key] = vc.order.PushFront(&cacheEntry{key: key, result: result})
+
+	if vc.order.Len() > vc.size {
+		oldest := vc.order.Back()
+		vc.order.Remove(oldest)
+		delete(vc.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// Removes any cached result for a key.
+func (vc *valueCache) remove(key Path) {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+
+	if element, ok := vc.entries[key]; ok {
+		vc.order.Remove(element)
+		delete(vc.entries, key)
+	}
+}
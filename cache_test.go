@@ -0,0 +1,65 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestCacheRevalidation(t *testing.T) {
+	ref, transfers := "0eb4e5dca3a2ec1b", 0
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"`+ref+`"` {
+			w.WriteHeader(304)
+			return
+		}
+		transfers++
+		w.Header().Set("Content-Location", "/v0/users/bob/refs/"+ref)
+		fmt.Fprintf(w, `{"ref":%q}`, ref)
+	}))
+	c.EnableCache(10)
+
+	for i := 0; i < 3; i++ {
+		result, err := c.Get("users", "bob")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Path.Ref != ref || string(result.RawValue) != `{"ref":"0eb4e5dca3a2ec1b"}` {
+			t.Errorf("unexpected result %+v", result)
+		}
+	}
+	if transfers != 1 {
+		t.Errorf("expected the value to be transferred once, got %d", transfers)
+	}
+
+	ref = "82eafab14dc84ed3"
+	result, err := c.Get("users", "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Path.Ref != ref || transfers != 2 {
+		t.Errorf("expected a modified value to be transferred again, got %+v", result)
+	}
+}
+
+func TestValueCacheEviction(t *testing.T) {
+	vc := newValueCache(2)
+	a, b, d := Path{Key: "a"}, Path{Key: "b"}, Path{Key: "d"}
+
+	vc.add(a, KVResult{Path: a})
+	vc.add(b, KVResult{Path: b})
+	vc.get(a)
+	vc.add(d, KVResult{Path: d})
+
+	if _, ok := vc.get(b); ok {
+		t.Error("expected the least recently used entry to be evicted")
+	}
+	if _, ok := vc.get(a); !ok {
+		t.Error("expected a recently used entry to be kept")
+	}
+	if _, ok := vc.get(d); !ok {
+		t.Error("expected the newest entry to be kept")
+	}
+}
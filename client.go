@@ -11,12 +11,17 @@
 package gorc
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 )
 
@@ -54,12 +59,75 @@ var (
 			return net.DialTimeout(network, addr, DefaultDialTimeout)
 		},
 	}
+
+	// If set, this is called with every OrchestrateError before it is
+	// returned, so that its Message can be scrubbed of anything that must not
+	// reach logs or error reports. The Message is taken from the response,
+	// never the request body, and has already had the auth token removed.
+	RedactError func(e *OrchestrateError)
 )
 
 // An Orchestrate Client object.
 type Client struct {
 	httpClient *http.Client
 	authToken  string
+
+	// Adds credentials to requests in place of the auth token, if set. See
+	// SetAuthenticator.
+	authenticator Authenticator
+
+	// The root URL requests are made against, always ending in a slash.
+	baseURL string
+
+	// The User-Agent header sent with each request, if not empty.
+	userAgent string
+
+	// Headers added to every request, after all others. See WithHeaders.
+	extraHeaders map[string]string
+
+	// Recently read values, if caching is enabled. See EnableCache.
+	cache *valueCache
+
+	// Holds a token for each request in flight when the number of concurrent
+	// requests is limited. See SetMaxConcurrency.
+	inFlight chan struct{}
+
+	// The number of times a failed safe request will be retried, and the
+	// delay before the first retry. See SetRetries.
+	maxRetries   int
+	retryBackoff time.Duration
+
+	// Called after every request with how it was retried. See SetRetryHook.
+	retryHook func(RetryInfo)
+
+	// The deadline applied to requests made without one. See
+	// SetDefaultTimeout.
+	defaultTimeout time.Duration
+
+	// The details of the most recent response. See LastResponseMeta.
+	last *lastResponse
+
+	// Paces requests when their rate is limited. See SetRateLimit.
+	limiter *rateLimiter
+
+	// The reads in flight, when concurrent reads of a key share a request.
+	// See SetSingleFlight.
+	flights *flightGroup
+
+	// Applied to values as they are stored and read. See SetValueTransforms.
+	outbound ValueTransform
+	inbound  ValueTransform
+
+	// Whether requests that could change data are refused. See WithReadOnly.
+	readOnly bool
+
+	// Generates the ID sent with each request, if set. See
+	// SetRequestIDGenerator.
+	requestID func() string
+
+	// The context requests are made with, if not the background. See
+	// WithContext.
+	ctx context.Context
 }
 
 // An implementation of 'error' that exposes all the orchestrate specific
@@ -73,13 +141,43 @@ type OrchestrateError struct {
 
 	// The Orchestrate specific message representing the error.
 	Message string `json:"message"`
+
+	// The ref the key held when a conditional write failed with 412, if the
+	// server reported it. A compare-and-swap loop can retry against this ref
+	// without reading the value again.
+	CurrentRef string `json:"-"`
+
+	// The ID the failed request was sent with, if any. See
+	// SetRequestIDGenerator.
+	RequestID string `json:"-"`
 }
 
+// Sentinel errors that an OrchestrateError can be tested against with
+// errors.Is, according to its status code.
+var (
+	// The request did not carry valid credentials (401).
+	ErrUnauthorized = errors.New("unauthorized")
+
+	// The credentials are not permitted to perform the request (403).
+	ErrForbidden = errors.New("forbidden")
+
+	// The requested item does not exist (404).
+	ErrNotFound = errors.New("not found")
+
+	// The condition of a conditional write, such as the ref given to
+	// PutIfUnmodified, did not hold (412).
+	ErrPreconditionFailed = errors.New("precondition failed")
+)
+
 // A representation of a Key/Value object's path within Orchestrate.
 type Path struct {
 	Collection string `json:"collection"`
 	Key        string `json:"key"`
 	Ref        string `json:"ref"`
+
+	// Set on paths listed from a key's history when the ref marks a delete
+	// rather than holding a value.
+	Tombstone bool `json:"tombstone,omitempty"`
 }
 
 // Returns a new Client object that will use the given authToken for
@@ -95,16 +193,65 @@ func NewClientWithTransport(authToken string, transport *http.Transport) *Client
 	return &Client{
 		httpClient: &http.Client{Transport: transport},
 		authToken:  authToken,
+		baseURL:    rootUri,
+		last:       new(lastResponse),
 	}
 }
 
+// Returns a Client that authorizes its requests with the given authToken but
+// otherwise shares this Client's configuration and connection pool. This is
+// cheap enough to call per request, for example when proxying requests on
+// behalf of several Orchestrate accounts.
+func (c *Client) WithAuthToken(authToken string) *Client {
+	clone := *c
+	clone.authToken = authToken
+	clone.authenticator = nil
+	return &clone
+}
+
+// Returns a Client that adds the given headers to each of its requests but
+// otherwise shares this Client's configuration and connection pool. This is
+// intended for opting in to experimental API behavior on individual calls.
+// The headers are applied last, so they replace any the client would send
+// itself, such as Content-Type.
+func (c *Client) WithHeaders(headers map[string]string) *Client {
+	clone := *c
+	clone.extraHeaders = make(map[string]string, len(c.extraHeaders)+len(headers))
+	for k, v := range c.extraHeaders {
+		clone.extraHeaders[k] = v
+	}
+	for k, v := range headers {
+		clone.extraHeaders[k] = v
+	}
+	return &clone
+}
+
+// Returns a Client that makes each of its requests with the given context but
+// otherwise shares this Client's configuration and connection pool, so that
+// any call can be cancelled or given a deadline:
+//
+//	result, err := c.WithContext(ctx).Get("users", "bob")
+//
+// The context governs a request from when it is started until its response
+// body is closed, including the waits between retries, for a rate limit and
+// for a slot under SetMaxConcurrency.
+// A read shared with other callers by SetSingleFlight is made with the
+// context of the caller that started it.
+func (c *Client) WithContext(ctx context.Context) *Client {
+	clone := *c
+	clone.ctx = ctx
+	return &clone
+}
+
 // Check that Orchestrate is reachable.
 func (c *Client) Ping() error {
 	resp, err := c.doRequest("HEAD", "", nil, nil)
 	if err != nil {
-		return nil
+		return err
 	}
 
+	defer resp.Body.Close()
+
 	if resp.StatusCode != 200 {
 		return newError(resp)
 	}
@@ -120,35 +267,333 @@ func newError(resp *http.Response) error {
 	}
 	if data, err := ioutil.ReadAll(resp.Body); err != nil {
 		oe.Message = fmt.Sprintf("Can not read HTTP response: %s", err)
-		return oe
 	} else if err := json.Unmarshal(data, oe); err != nil {
 		oe.Message = fmt.Sprintf("Can not unmarshal JSON response '''%s''': %s", string(data), err)
-		return oe
+	}
+
+	if resp.StatusCode == 412 {
+		oe.CurrentRef, _ = refFromHeader(resp.Header)
+	}
+
+	// The response may echo the credentials the request was sent with.
+	if resp.Request != nil {
+		oe.RequestID = resp.Request.Header.Get(RequestIDHeader)
+		if token, _, ok := resp.Request.BasicAuth(); ok && token != "" {
+			oe.Message = strings.Replace(oe.Message, token, "[REDACTED]", -1)
+		}
+		for _, secret := range requestCredentials(resp.Request) {
+			oe.Message = strings.Replace(oe.Message, secret, "[REDACTED]", -1)
+		}
+	}
+
+	if RedactError != nil {
+		RedactError(oe)
 	}
 
 	return oe
 }
 
+// Strips any credentials from the URL reported by a transport error, which
+// can carry them if they were given as part of the base URL.
+func redactTransportError(err error) error {
+	if uerr, ok := err.(*url.Error); ok {
+		if u, perr := url.Parse(uerr.URL); perr == nil && u.User != nil {
+			u.User = nil
+			uerr.URL = u.String()
+		}
+	}
+	return err
+}
+
+// Builds a trailing URI from the given path segments, escaping each one so
+// that reserved characters within collection names, keys and kinds can not
+// alter the structure of the request path.
+func buildURI(segments ...string) string {
+	escaped := make([]string, len(segments))
+	for i, segment := range segments {
+		escaped[i] = url.PathEscape(segment)
+	}
+	return strings.Join(escaped, "/")
+}
+
+// Splits the path of a Location style response header into its segments,
+// relative to the API root. The root is the path of the first of roots, such
+// as a client's base URL, that the location lies within or, failing that, of
+// the Orchestrate API itself. Segments are returned unescaped.
+func locationSegments(location string, roots ...string) ([]string, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, root := range append(roots, rootUri) {
+		r, err := url.Parse(root)
+		if err != nil {
+			return nil, err
+		}
+
+		if strings.HasPrefix(u.Path, r.Path) {
+			return strings.Split(strings.TrimSuffix(u.Path[len(r.Path):], "/"), "/"), nil
+		}
+	}
+
+	return nil, fmt.Errorf("unexpected location %q", location)
+}
+
 func (e OrchestrateError) Error() string {
+	if e.RequestID != "" {
+		return fmt.Sprintf("%s (%d): %s (request %s)", e.Status, e.StatusCode, e.Message, e.RequestID)
+	}
 	return fmt.Sprintf("%s (%d): %s", e.Status, e.StatusCode, e.Message)
 }
 
-// Executes an HTTP request.
+// Reports whether the error matches one of the sentinel errors, for use with
+// errors.Is.
+func (e OrchestrateError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == 401
+	case ErrForbidden:
+		return e.StatusCode == 403
+	case ErrNotFound:
+		return e.StatusCode == 404
+	case ErrPreconditionFailed:
+		return e.StatusCode == 412
+	}
+	return false
+}
+
+// Executes an HTTP request, retrying it as configured by SetRetries, within
+// the deadline set by SetDefaultTimeout.
 func (c *Client) doRequest(method, trailing string, headers map[string]string, body io.Reader) (*http.Response, error) {
-	req, err := http.NewRequest(method, rootUri+trailing, body)
+	if c.readOnly && method != "GET" && method != "HEAD" {
+		return nil, ErrReadOnly
+	}
+
+	ctx, cancel := c.requestContext(c.baseContext())
+	req, err := c.newRequest(ctx, method, trailing, headers, body)
 	if err != nil {
+		cancel()
 		return nil, err
 	}
 
-	req.SetBasicAuth(c.authToken, "")
+	resp, err := c.sendWithRetries(req)
+	if err != nil {
+		cancel()
+		if id := req.Header.Get(RequestIDHeader); id != "" {
+			err = fmt.Errorf("request %s: %w", id, err)
+		}
+		return nil, err
+	}
 
-	for k, v := range headers {
-		req.Header.Add(k, v)
+	c.recordResponse(resp)
+
+	resp.Body = &cancelingBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// Returns the context the client's requests are made with, as set by
+// WithContext.
+func (c *Client) baseContext() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// Sends a request, retrying it as configured by SetRetries until it succeeds
+// or its context is done.
+func (c *Client) sendWithRetries(req *http.Request) (resp *http.Response, err error) {
+	info := RetryInfo{Method: req.Method, Path: req.URL.Path, RequestID: req.Header.Get(RequestIDHeader)}
+	if c.retryHook != nil {
+		defer func() {
+			info.Err = err
+			c.retryHook(info)
+		}()
+	}
+
+	ctx := req.Context()
+	for attempt := 0; ; attempt++ {
+		info.Attempts++
+		resp, err = c.send(req)
+		if resp != nil {
+			info.LastStatus = resp.StatusCode
+		}
+		if attempt >= c.maxRetries || ctx.Err() != nil || !shouldRetry(req, resp, err) {
+			return resp, err
+		}
+
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		delay := c.retryBackoff << uint(attempt)
+		select {
+		case <-time.After(delay):
+			info.TotalDelay += delay
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		if req, err = rewindRequest(req); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Sends a single HTTP request.
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+	if c.limiter != nil {
+		if err := c.limiter.wait(req.Context()); err != nil {
+			return nil, err
+		}
+	}
+
+	release, err := c.acquire(req.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		release()
+		return nil, redactTransportError(err)
+	}
+
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// Returns a copy of a request that has already been sent, with a fresh body
+// so that it can be sent again.
+func rewindRequest(req *http.Request) (*http.Request, error) {
+	clone := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		clone.Body = body
+	}
+	return clone, nil
+}
+
+// Decodes the JSON body of a response into v, describing what was being
+// decoded in any error. A body that can not be decoded in its entirety, such
+// as one cut short by a dropped connection, is an error; callers must discard
+// v rather than use what was decoded before the failure.
+func decodeResponse(resp *http.Response, v interface{}, what string) error {
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("can not decode %s: %w", what, err)
+	}
+	return nil
+}
+
+// Decodes the results array of a JSON response body one element at a time,
+// calling decode with the decoder positioned at each element in turn, so that
+// a page of any size is never held in memory at once. The other fields of the
+// response are skipped. An error from decode stops the stream and is returned.
+func streamResults(resp *http.Response, what string, decode func(*json.Decoder) error) error {
+	decoder := json.NewDecoder(resp.Body)
+
+	if token, err := decoder.Token(); err != nil {
+		return fmt.Errorf("can not decode %s: %w", what, err)
+	} else if token != json.Delim('{') {
+		return fmt.Errorf("can not decode %s: unexpected %v", what, token)
+	}
+
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return fmt.Errorf("can not decode %s: %w", what, err)
+		}
+
+		if token != "results" {
+			var skipped json.RawMessage
+			if err := decoder.Decode(&skipped); err != nil {
+				return fmt.Errorf("can not decode %s: %w", what, err)
+			}
+			continue
+		}
+
+		if token, err := decoder.Token(); err != nil {
+			return fmt.Errorf("can not decode %s: %w", what, err)
+		} else if token != json.Delim('[') {
+			return fmt.Errorf("can not decode %s: unexpected %v", what, token)
+		}
+
+		for decoder.More() {
+			if err := decode(decoder); err != nil {
+				return err
+			}
+		}
+
+		if _, err := decoder.Token(); err != nil {
+			return fmt.Errorf("can not decode %s: %w", what, err)
+		}
+	}
+
+	return nil
+}
+
+// Encodes a value as JSON into a buffer. Request bodies held in a buffer are
+// sent with a fixed Content-Length rather than chunked, and can be replayed
+// if the request has to be retried.
+func encodeJSON(value interface{}) (*bytes.Buffer, error) {
+	return encodeJSONWith(value, nil)
+}
+
+// Like encodeJSON, except that configure, if not nil, is given the encoder to
+// adjust before the value is encoded.
+func encodeJSONWith(value interface{}, configure func(*json.Encoder)) (*bytes.Buffer, error) {
+	buf := new(bytes.Buffer)
+	encoder := json.NewEncoder(buf)
+	if configure != nil {
+		configure(encoder)
+	}
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// Builds an authorized HTTP request against the API. When body is a
+// *bytes.Buffer, *bytes.Reader or *strings.Reader the request's ContentLength
+// and GetBody are set from it.
+func (c *Client) newRequest(ctx context.Context, method, trailing string, headers map[string]string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+trailing, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.authenticator == nil {
+		req.SetBasicAuth(c.authToken, "")
+	}
+
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
 	}
 
 	if method == "PUT" {
-		req.Header.Add("Content-Type", "application/json")
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if c.requestID != nil {
+		req.Header.Set(RequestIDHeader, c.requestID())
+	}
+
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	for k, v := range c.extraHeaders {
+		req.Header.Set(k, v)
+	}
+
+	if c.authenticator != nil {
+		req = c.applyAuthenticator(req)
 	}
 
-	return c.httpClient.Do(req)
+	return req, nil
 }
@@ -0,0 +1,126 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// A link to another page of results, as returned in the next and prev fields
+// of key/value, search, event search, event and graph result sets. The empty
+// Cursor links to nothing.
+type Cursor string
+
+// Returned when following an empty Cursor.
+var ErrNoCursor = errors.New("no page to follow")
+
+// Get the page of results a cursor links to, decoding it into results. The
+// results must be a pointer to the same kind of result set the cursor came
+// from: *KVResults, *SearchResults, *EventSearchResults, *EventResults or
+// *GraphResults. Every page of any kind can then be walked with one loop:
+//
+//	for cursor := results.Next; cursor != ""; cursor = results.Next {
+//	    if err := c.Follow(cursor, results); err != nil {
+//	        return err
+//	    }
+//	    // Use results.Results
+//	}
+//
+// The contents of results are replaced, and are unspecified if an error is
+// returned. Key/value results are decoded as described by ListInto, reusing
+// the capacity of their Results slice.
+func (c *Client) Follow(cursor Cursor, results interface{}) error {
+	if cursor == "" {
+		return ErrNoCursor
+	}
+
+	trailingUri, err := c.cursorTrailingURI(cursor)
+	if err != nil {
+		return err
+	}
+
+	switch r := results.(type) {
+	case *KVResults:
+		return c.doListInto(trailingUri, r)
+
+	case *SearchResults:
+		page, err := c.doSearch(trailingUri)
+		if err != nil {
+			return err
+		}
+		*r = *page
+
+	case *EventSearchResults:
+		page, err := c.doSearchEvents(trailingUri)
+		if err != nil {
+			return err
+		}
+		*r = *page
+
+	case *EventResults:
+		page, err := c.doGetEvents(trailingUri, cursor.eventKind(c.baseURL))
+		if err != nil {
+			return err
+		}
+		*r = *page
+
+	case *GraphResults:
+		page, err := c.doGetRelations(trailingUri)
+		if err != nil {
+			return err
+		}
+		*r = *page
+
+	default:
+		return fmt.Errorf("can not follow a cursor into %T", results)
+	}
+
+	return nil
+}
+
+// Returns the part of a cursor's link that follows the API root, such as
+// "collection?limit=10&afterKey=k" for "/v0/collection?limit=10&afterKey=k".
+// The root is the path of the client's base URL or, failing that, of the
+// Orchestrate API itself, which is what links from a proxy that does not
+// rewrite them will contain. Links may be relative or absolute; the scheme
+// and host of an absolute link are ignored so that the request still goes to
+// the client's base URL. The link is already escaped, so it is passed through
+// unchanged.
+func (c *Client) cursorTrailingURI(cur Cursor) (string, error) {
+	u, err := url.Parse(string(cur))
+	if err != nil {
+		return "", err
+	}
+
+	path := u.EscapedPath()
+	for _, root := range []string{c.baseURL, rootUri} {
+		r, err := url.Parse(root)
+		if err != nil {
+			return "", err
+		}
+
+		if strings.HasPrefix(path, r.EscapedPath()) {
+			trailingUri := path[len(r.EscapedPath()):]
+			if u.RawQuery != "" {
+				trailingUri += "?" + u.RawQuery
+			}
+			return trailingUri, nil
+		}
+	}
+
+	return "", fmt.Errorf("link %q is not within %s", cur, c.baseURL)
+}
+
+// Returns the event kind an events cursor refers to, or "" if the cursor does
+// not link to events. The cursor is resolved against roots as
+// locationSegments does.
+func (cur Cursor) eventKind(roots ...string) string {
+	segments, err := locationSegments(string(cur), roots...)
+	if err != nil || len(segments) < 4 || segments[2] != "events" {
+		return ""
+	}
+	return segments[3]
+}
@@ -0,0 +1,129 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestCursorTrailingURI(t *testing.T) {
+	tests := map[Cursor]string{
+		"/v0/users?limit=10&afterKey=bob":           "users?limit=10&afterKey=bob",
+		"/v0/users/bob/events/login?limit=10":       "users/bob/events/login?limit=10",
+		"/v0/us%2Fers?limit=10&afterKey=a%2Bb%26c":  "us%2Fers?limit=10&afterKey=a%2Bb%26c",
+		"/v0/users/bob/relations/follows?offset=20": "users/bob/relations/follows?offset=20",
+		"/v0/users": "users",
+		"https://api.orchestrate.io/v0/users?limit=10&afterKey=b": "users?limit=10&afterKey=b",
+		"http://elsewhere.example.com/v0/users?limit=10":          "users?limit=10",
+	}
+
+	c := NewClient("token")
+	for cursor, expected := range tests {
+		actual, err := c.cursorTrailingURI(cursor)
+		if err != nil {
+			t.Errorf("%q: %v", cursor, err)
+		} else if actual != expected {
+			t.Errorf("%q: got %q, expected %q", cursor, actual, expected)
+		}
+	}
+}
+
+func TestCursorTrailingURIBaseURL(t *testing.T) {
+	tests := map[Cursor]string{
+		"/orchestrate/v1/users?limit=10":                     "users?limit=10",
+		"https://proxy.example.com/orchestrate/v1/users?a=b": "users?a=b",
+		"/v0/users?limit=10":                                 "users?limit=10",
+	}
+
+	c := NewClientWithOptions("token", WithBaseURL("https://proxy.example.com/orchestrate/v1/"))
+	for cursor, expected := range tests {
+		actual, err := c.cursorTrailingURI(cursor)
+		if err != nil {
+			t.Errorf("%q: %v", cursor, err)
+		} else if actual != expected {
+			t.Errorf("%q: got %q, expected %q", cursor, actual, expected)
+		}
+	}
+
+	for _, cursor := range []Cursor{"/v1/users?limit=10", "users?limit=10", "/other/v0/users"} {
+		if _, err := c.cursorTrailingURI(cursor); err == nil {
+			t.Errorf("%q: expected an error", cursor)
+		}
+	}
+}
+
+func TestFollowEmptyCursor(t *testing.T) {
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request for %s", r.URL)
+	}))
+
+	if err := c.Follow("", new(KVResults)); err != ErrNoCursor {
+		t.Errorf("expected ErrNoCursor, got %v", err)
+	}
+	if _, err := c.ListGetNext(new(KVResults)); err != ErrNoCursor {
+		t.Errorf("expected ErrNoCursor, got %v", err)
+	}
+}
+
+func TestFollowEvents(t *testing.T) {
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("beforeEvent") == "" {
+			fmt.Fprint(w, `{"count":1,"results":[{"timestamp":2,"ordinal":1}],"next":"/v0/users/bob/events/login?limit=1&beforeEvent=2/1"}`)
+			return
+		}
+		fmt.Fprint(w, `{"count":1,"results":[{"timestamp":1,"ordinal":1}]}`)
+	}))
+
+	results, err := c.GetEventsLimit("users", "bob", "login", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pages := 1
+	for cursor := results.Next; cursor != ""; cursor = results.Next {
+		if err := c.Follow(cursor, results); err != nil {
+			t.Fatal(err)
+		}
+		pages++
+	}
+
+	if pages != 2 {
+		t.Errorf("expected 2 pages, got %d", pages)
+	}
+	if len(results.Results) != 1 || results.Results[0].Timestamp != 1 || results.Results[0].Kind != "login" {
+		t.Errorf("unexpected last page %+v", results)
+	}
+}
+
+func TestListPagingReservedCharacters(t *testing.T) {
+	var afterKeys []string
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		after := r.URL.Query().Get("afterKey")
+		afterKeys = append(afterKeys, after)
+		if after == "a+b%20c&d" {
+			next := "/v0/users?" + url.Values{"limit": {"2"}, "afterKey": {"e+f%20g&h"}}.Encode()
+			fmt.Fprintf(w, `{"count":1,"results":[{"path":{"collection":"users","key":"e+f%%20g&h"}}],"next":%q}`, next)
+			return
+		}
+		fmt.Fprint(w, `{"count":0,"results":[]}`)
+	}))
+
+	results, err := c.ListAfter("users", "a+b%20c&d", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results.Results) != 1 || results.Results[0].Path.Key != "e+f%20g&h" {
+		t.Fatalf("unexpected first page %+v", results)
+	}
+
+	if _, err := c.ListGetNext(results); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(afterKeys) != 2 || afterKeys[1] != "e+f%20g&h" {
+		t.Errorf("expected the cursor to be followed without re-escaping, got afterKeys %q", afterKeys)
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// Limit every request made without a deadline of its own to d, from when it
+// is started until its response body is closed, including any retries. This
+// is a safety net against hung requests; a deadline already carried by a
+// request's context always takes precedence. Setting d to zero, the default,
+// removes the limit.
+//
+// This should be called before the Client is shared between goroutines.
+func (c *Client) SetDefaultTimeout(d time.Duration) {
+	c.defaultTimeout = d
+}
+
+// Returns the context a request should be made with, derived from parent,
+// and the function that must be called once the request has finished.
+func (c *Client) requestContext(parent context.Context) (context.Context, context.CancelFunc) {
+	if c.defaultTimeout <= 0 {
+		return parent, func() {}
+	}
+	if _, ok := parent.Deadline(); ok {
+		return parent, func() {}
+	}
+	return context.WithTimeout(parent, c.defaultTimeout)
+}
+
+// A response body that cancels its request's context when closed.
+type cancelingBody struct {
+	io.ReadCloser
+	once   sync.Once
+	cancel context.CancelFunc
+}
+
+func (b *cancelingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.cancel)
+	return err
+}
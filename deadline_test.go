@@ -0,0 +1,53 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestDefaultTimeout(t *testing.T) {
+	attempts := 0
+	c := newTestClient(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		attempts++
+		if _, ok := req.Context().Deadline(); !ok {
+			t.Error("expected the request to carry a deadline")
+		}
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	}))
+	c.SetDefaultTimeout(10 * time.Millisecond)
+	c.SetRetries(3, time.Millisecond)
+
+	_, err := c.Get("users", "bob")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline to be exceeded, got %v", err)
+	}
+	if attempts != 1 {
+		t.Errorf("expected no retries once the deadline passed, got %d attempts", attempts)
+	}
+}
+
+func TestRequestContextPrecedence(t *testing.T) {
+	c := NewClient("token")
+
+	ctx, cancel := c.requestContext(context.Background())
+	defer cancel()
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("expected no deadline without a default timeout")
+	}
+
+	c.SetDefaultTimeout(time.Hour)
+	parent, parentCancel := context.WithTimeout(context.Background(), time.Minute)
+	defer parentCancel()
+
+	ctx, cancel = c.requestContext(parent)
+	defer cancel()
+	if deadline, _ := ctx.Deadline(); deadline.After(time.Now().Add(2 * time.Minute)) {
+		t.Error("expected the caller's deadline to take precedence")
+	}
+}
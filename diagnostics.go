@@ -0,0 +1,72 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+import (
+	"net/url"
+	"path"
+	"strconv"
+)
+
+// Returns a description of the Client's effective configuration, for
+// including in bug reports. The auth token is never included, only its length
+// and last four characters.
+func (c *Client) Diagnostics() map[string]string {
+	diagnostics := map[string]string{
+		"base_url":           c.baseURL,
+		"api_version":        apiVersion(c.baseURL),
+		"auth_token":         redactToken(c.authToken),
+		"authenticator":      "basic",
+		"user_agent":         c.userAgent,
+		"custom_http_client": strconv.FormatBool(c.httpClient.Transport != DefaultTransport),
+		"http_timeout":       c.httpClient.Timeout.String(),
+		"default_timeout":    c.defaultTimeout.String(),
+		"max_retries":        strconv.Itoa(c.maxRetries),
+		"retry_backoff":      c.retryBackoff.String(),
+		"max_concurrency":    strconv.Itoa(cap(c.inFlight)),
+		"cache_size":         "0",
+		"extra_headers":      strconv.Itoa(len(c.extraHeaders)),
+		"single_flight":      strconv.FormatBool(c.flights != nil),
+		"read_only":          strconv.FormatBool(c.readOnly),
+		"request_ids":        strconv.FormatBool(c.requestID != nil),
+	}
+
+	if c.authenticator != nil {
+		diagnostics["authenticator"] = "custom"
+	}
+
+	if c.cache != nil {
+		diagnostics["cache_size"] = strconv.Itoa(c.cache.size)
+	}
+
+	diagnostics["rate_limit"] = "0"
+	if c.limiter != nil {
+		diagnostics["rate_limit"] = strconv.FormatFloat(c.limiter.rate, 'f', -1, 64)
+	}
+
+	switch c.httpClient.Transport.(type) {
+	case *recordingTransport:
+		diagnostics["traffic"] = "recording"
+	case *replayTransport:
+		diagnostics["traffic"] = "replaying"
+	}
+
+	return diagnostics
+}
+
+// Returns the API version named by the last segment of the base URL's path.
+func apiVersion(baseURL string) string {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return ""
+	}
+	return path.Base(path.Clean("/" + u.Path))
+}
+
+// Describes a token without revealing it.
+func redactToken(token string) string {
+	if len(token) <= 8 {
+		return strconv.Itoa(len(token)) + " characters"
+	}
+	return strconv.Itoa(len(token)) + " characters, ending " + token[len(token)-4:]
+}
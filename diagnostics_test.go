@@ -0,0 +1,44 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiagnostics(t *testing.T) {
+	const token = "a5b2c8d4-1f3e-4a7b-9c6d-0e8f2a4b6c1d"
+
+	c := NewClientWithOptions(token, WithUserAgent("app/1.0"), WithRetry(3, time.Second))
+	c.EnableCache(100)
+
+	diagnostics := c.Diagnostics()
+	expected := map[string]string{
+		"base_url":           "https://api.orchestrate.io/v0/",
+		"api_version":        "v0",
+		"auth_token":         "36 characters, ending 6c1d",
+		"authenticator":      "basic",
+		"user_agent":         "app/1.0",
+		"custom_http_client": "false",
+		"max_retries":        "3",
+		"retry_backoff":      "1s",
+		"cache_size":         "100",
+	}
+	for key, value := range expected {
+		if diagnostics[key] != value {
+			t.Errorf("%s: expected %q, got %q", key, value, diagnostics[key])
+		}
+	}
+
+	for key, value := range diagnostics {
+		if strings.Contains(value, token[:8]) {
+			t.Errorf("%s: the auth token must not be shown, got %q", key, value)
+		}
+	}
+
+	if short := NewClient("abc").Diagnostics()["auth_token"]; short != "3 characters" {
+		t.Errorf("expected a short token to be hidden entirely, got %q", short)
+	}
+}
@@ -3,16 +3,23 @@
 package gorc
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"io"
+	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
+	"sync"
+	"time"
 )
 
 // Holds results returned from an Events query.
 type EventResults struct {
 	Count   uint64  `json:"count"`
 	Results []Event `json:"results"`
+	Next    Cursor  `json:"next,omitempty"`
 }
 
 // An individual event.
@@ -20,13 +27,54 @@ type Event struct {
 	Ordinal   uint64          `json:"ordinal"`
 	Timestamp uint64          `json:"timestamp"`
 	RawValue  json.RawMessage `json:"value"`
+
+	// The type of the event. This is filled in by the client from the query
+	// that produced the event.
+	Kind string `json:"kind,omitempty"`
+
+	// The ref of the event's current value, as listed in its path, for use
+	// with conditional updates and deletes of the event.
+	Ref string `json:"ref,omitempty"`
+}
+
+// Decodes an event as listed by Orchestrate, taking its Ref from the path
+// listed with it.
+func (r *Event) UnmarshalJSON(data []byte) error {
+	type event Event
+	var decoded struct {
+		event
+		Path struct {
+			Ref string `json:"ref"`
+		} `json:"path"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	*r = Event(decoded.event)
+	if r.Ref == "" {
+		r.Ref = decoded.Path.Ref
+	}
+	return nil
 }
 
 // Get latest events of a particular type from specified collection-key pair.
 func (c *Client) GetEvents(collection, key, kind string) (*EventResults, error) {
-	trailingUri := collection + "/" + key + "/events/" + kind
+	trailingUri := buildURI(collection, key, "events", kind)
+
+	return c.doGetEvents(trailingUri, kind)
+}
 
-	return c.doGetEvents(trailingUri)
+// Get at most limit of the latest events of a particular type from specified
+// collection-key pair.
+func (c *Client) GetEventsLimit(collection, key, kind string, limit int) (*EventResults, error) {
+	queryVariables := url.Values{
+		"limit": []string{strconv.Itoa(limit)},
+	}
+
+	trailingUri := buildURI(collection, key, "events", kind) + "?" + queryVariables.Encode()
+
+	return c.doGetEvents(trailingUri, kind)
 }
 
 // Get all events of a particular type from specified collection-key pair in a
@@ -37,35 +85,369 @@ func (c *Client) GetEventsInRange(collection, key, kind string, start int64, end
 		"end":   []string{strconv.FormatInt(end, 10)},
 	}
 
-	trailingUri := collection + "/" + key + "/events/" + kind + "?" + queryVariables.Encode()
+	trailingUri := buildURI(collection, key, "events", kind) + "?" + queryVariables.Encode()
+
+	return c.doGetEvents(trailingUri, kind)
+}
+
+// Get at most limit events of a particular type from specified collection-key
+// pair in a range of wall clock time, from start up to end.
+func (c *Client) GetEventsBetween(collection, key, kind string, start, end time.Time, limit int) (*EventResults, error) {
+	queryVariables := url.Values{
+		"start": []string{strconv.FormatInt(millis(start), 10)},
+		"end":   []string{strconv.FormatInt(millis(end), 10)},
+		"limit": []string{strconv.Itoa(limit)},
+	}
+
+	trailingUri := buildURI(collection, key, "events", kind) + "?" + queryVariables.Encode()
+
+	return c.doGetEvents(trailingUri, kind)
+}
+
+// Options controlling which page of events GetEventsPage returns.
+type EventListOptions struct {
+	// The size of the page. Zero leaves it to the server.
+	Limit int
+
+	// List only the events older than BeforeEvent, or newer than
+	// AfterEvent, each named by an event's timestamp and ordinal as
+	// returned by EventPosition, or by a timestamp alone. Either may be
+	// empty to leave that end open.
+	BeforeEvent string
+	AfterEvent  string
+}
+
+// Returns the position of the event stored under the given timestamp and
+// ordinal, for use in EventListOptions.
+func EventPosition(timestamp int64, ordinal uint64) string {
+	return strconv.FormatInt(timestamp, 10) + "/" + strconv.FormatUint(ordinal, 10)
+}
+
+// Get a page of the events of a particular type from specified collection-key
+// pair, newest first, as chosen by opts. Subsequent pages can be fetched with
+// GetEventsNext.
+func (c *Client) GetEventsPage(collection, key, kind string, opts EventListOptions) (*EventResults, error) {
+	queryVariables := url.Values{}
+	if opts.Limit > 0 {
+		queryVariables.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if opts.BeforeEvent != "" {
+		queryVariables.Set("beforeEvent", opts.BeforeEvent)
+	}
+	if opts.AfterEvent != "" {
+		queryVariables.Set("afterEvent", opts.AfterEvent)
+	}
+
+	trailingUri := buildURI(collection, key, "events", kind)
+	if len(queryVariables) > 0 {
+		trailingUri += "?" + queryVariables.Encode()
+	}
+
+	return c.doGetEvents(trailingUri, kind)
+}
+
+// Get the page of events that follow that provided set, by following the next
+// link returned with them.
+func (c *Client) GetEventsNext(results *EventResults) (*EventResults, error) {
+	next := new(EventResults)
+	if err := c.Follow(results.Next, next); err != nil {
+		return nil, err
+	}
+	return next, nil
+}
+
+// Call fn with each of the latest events, at most limit of them, of a
+// particular type from specified collection-key pair, in turn. The events
+// are decoded one at a time as the response arrives, so memory use does not
+// grow with the size of the page. An error returned from fn stops the stream
+// and is returned.
+//
+// The stream holds its slot under SetMaxConcurrency until it ends, so fn
+// waits for another slot if it makes requests with this Client; with a limit
+// of one such a request can never be sent.
+func (c *Client) GetEventsStream(collection, key, kind string, limit int, fn func(Event) error) error {
+	queryVariables := url.Values{
+		"limit": []string{strconv.Itoa(limit)},
+	}
+
+	trailingUri := buildURI(collection, key, "events", kind) + "?" + queryVariables.Encode()
+
+	resp, err := c.doRequest("GET", trailingUri, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return newError(resp)
+	}
+
+	return streamResults(resp, "event results", func(decoder *json.Decoder) error {
+		var event Event
+		if err := decoder.Decode(&event); err != nil {
+			return fmt.Errorf("can not decode event: %w", err)
+		}
+		event.Kind = kind
+		return fn(event)
+	})
+}
+
+// Get the latest events of several types from the specified collection-key
+// pair, merged into a single result set that is ordered newest first. Each
+// type is fetched concurrently and at most limit events are returned in
+// total; the Kind of each event tells them apart.
+func (c *Client) GetEventsMultiKind(collection, key string, kinds []string, limit int) (*EventResults, error) {
+	queryVariables := url.Values{
+		"limit": []string{strconv.Itoa(limit)},
+	}
+
+	var wg sync.WaitGroup
+	pages := make([]*EventResults, len(kinds))
+	errs := make([]error, len(kinds))
+	for i, kind := range kinds {
+		wg.Add(1)
+		go func(i int, kind string) {
+			defer wg.Done()
+			trailingUri := buildURI(collection, key, "events", kind) + "?" + queryVariables.Encode()
+			pages[i], errs[i] = c.doGetEvents(trailingUri, kind)
+		}(i, kind)
+	}
+	wg.Wait()
+
+	results := new(EventResults)
+	for i := range kinds {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		results.Results = append(results.Results, pages[i].Results...)
+	}
+
+	sort.SliceStable(results.Results, func(i, j int) bool {
+		a, b := results.Results[i], results.Results[j]
+		if a.Timestamp != b.Timestamp {
+			return a.Timestamp > b.Timestamp
+		}
+		return a.Ordinal > b.Ordinal
+	})
+
+	if len(results.Results) > limit {
+		results.Results = results.Results[:limit]
+	}
+	results.Count = uint64(len(results.Results))
 
-	return c.doGetEvents(trailingUri)
+	return results, nil
+}
+
+// Get the single event of a particular type stored under the given timestamp
+// and ordinal of a collection-key pair, along with the ref of its value. If
+// there is no such event the returned error matches ErrNotFound.
+func (c *Client) GetEvent(collection, key, kind string, timestamp int64, ordinal uint64) (*Event, error) {
+	resp, err := c.doRequest("GET", eventURI(collection, key, kind, timestamp, ordinal), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, newError(resp)
+	}
+
+	event := new(Event)
+	if err := decodeResponse(resp, event, "event"); err != nil {
+		return nil, err
+	}
+
+	event.Kind = kind
+	if event.Ref == "" {
+		event.Ref, _ = refFromHeader(resp.Header, c.baseURL)
+	}
+	if event.Timestamp == 0 && event.Ordinal == 0 {
+		event.Timestamp, event.Ordinal = uint64(timestamp), ordinal
+	}
+
+	return event, nil
+}
+
+// Replace the value of the event of a particular type stored under the given
+// timestamp and ordinal of a collection-key pair, returning the ref of its
+// new value.
+func (c *Client) UpdateEvent(collection, key, kind string, timestamp int64, ordinal uint64, value interface{}) (string, error) {
+	return c.doUpdateEvent(eventURI(collection, key, kind, timestamp, ordinal), nil, value)
+}
+
+// Like UpdateEvent, except that the event is only updated if ref is the ref of
+// its current value. Otherwise the returned error matches
+// ErrPreconditionFailed.
+func (c *Client) UpdateEventIfUnmodified(collection, key, kind string, timestamp int64, ordinal uint64, ref string, value interface{}) (string, error) {
+	headers := map[string]string{
+		"If-Match": quoteRef(ref),
+	}
+
+	return c.doUpdateEvent(eventURI(collection, key, kind, timestamp, ordinal), headers, value)
+}
+
+// Execute an event update, returning the new ref.
+func (c *Client) doUpdateEvent(trailingUri string, headers map[string]string, value interface{}) (string, error) {
+	buf, err := encodeJSON(value)
+	if err != nil {
+		return "", err
+	}
+
+	header, err := c.doPutEvent(trailingUri, headers, buf)
+	if err != nil {
+		return "", err
+	}
+
+	return refFromHeader(header, c.baseURL)
+}
+
+// Delete the event of a particular type stored under the given timestamp and
+// ordinal of a collection-key pair. The event is purged, leaving no trace in
+// the key's history.
+func (c *Client) DeleteEvent(collection, key, kind string, timestamp int64, ordinal uint64) error {
+	return c.doDelete(eventURI(collection, key, kind, timestamp, ordinal)+"?purge=true", nil)
+}
+
+// Like DeleteEvent, except that the event is only deleted if ref is the ref of
+// its current value. Otherwise the returned error matches
+// ErrPreconditionFailed.
+func (c *Client) DeleteEventIfUnmodified(collection, key, kind string, timestamp int64, ordinal uint64, ref string) error {
+	headers := map[string]string{
+		"If-Match": quoteRef(ref),
+	}
+
+	return c.doDelete(eventURI(collection, key, kind, timestamp, ordinal)+"?purge=true", headers)
 }
 
 // Put an event of the specified type to provided collection-key pair.
 func (c *Client) PutEvent(collection, key, kind string, value interface{}) error {
-	reader, writer := io.Pipe()
-	encoder := json.NewEncoder(writer)
+	buf, err := encodeJSON(value)
+	if err != nil {
+		return err
+	}
 
-	go func() { writer.CloseWithError(encoder.Encode(value)) }()
-	return c.PutEventRaw(collection, key, kind, reader)
+	return c.PutEventRaw(collection, key, kind, buf)
 }
 
 // Put an event of the specified type to provided collection-key pair.
 func (c *Client) PutEventRaw(collection, key, kind string, value io.Reader) error {
-	trailingUri := collection + "/" + key + "/events/" + kind
+	trailingUri := buildURI(collection, key, "events", kind)
+
+	_, err := c.doPutEvent(trailingUri, nil, value)
+	return err
+}
 
-	return c.doPutEvent(trailingUri, value)
+// Put an event of the specified type to provided collection-key pair,
+// returning the timestamp and ordinal the event was stored under.
+func (c *Client) PutEventAndLocate(collection, key, kind string, value interface{}) (int64, uint64, error) {
+	buf, err := encodeJSON(value)
+	if err != nil {
+		return 0, 0, err
+	}
 
+	return c.PutEventAndLocateRaw(collection, key, kind, buf)
+}
+
+// Put an event of the specified type to provided collection-key pair,
+// returning the timestamp and ordinal the event was stored under.
+func (c *Client) PutEventAndLocateRaw(collection, key, kind string, value io.Reader) (int64, uint64, error) {
+	trailingUri := buildURI(collection, key, "events", kind)
+
+	header, err := c.doPutEvent(trailingUri, nil, value)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return parseEventLocation(header.Get("Location"), c.baseURL)
+}
+
+// An event of the specified type for a collection-key pair, as written by
+// PutEvents.
+type EventWrite struct {
+	Collection string
+	Key        string
+	Kind       string
+	Value      interface{}
+}
+
+// Create an event of the specified type for provided collection-key pair with
+// a POST, returning the event as stored: its Timestamp and Ordinal, read from
+// the Location of the response, its Ref and Kind, and the value sent. These
+// are what UpdateEvent and DeleteEvent need to address the event later.
+func (c *Client) CreateEvent(collection, key, kind string, value interface{}) (*Event, error) {
+	buf, err := encodeJSON(value)
+	if err != nil {
+		return nil, err
+	}
+	raw := append(json.RawMessage(nil), buf.Bytes()...)
+
+	headers := map[string]string{
+		"Content-Type": "application/json",
+	}
+
+	resp, err := c.doRequest("POST", buildURI(collection, key, "events", kind), headers, buf)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 201 && resp.StatusCode != 204 {
+		return nil, newError(resp)
+	}
+
+	timestamp, ordinal, err := parseEventLocation(resp.Header.Get("Location"), c.baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	ref, _ := refFromHeader(resp.Header, c.baseURL)
+
+	return &Event{
+		Ordinal:   ordinal,
+		Timestamp: uint64(timestamp),
+		RawValue:  bytes.TrimSpace(raw),
+		Kind:      kind,
+		Ref:       ref,
+	}, nil
+}
+
+// Put many events with PutEvent, making at most concurrency requests at once.
+// Events for the same collection-key pair are written one after another, in
+// the order given, so they are stored in that order; events for different
+// pairs are written concurrently, in no particular order. A failure does not
+// stop the other events from being written, including later events for the
+// same pair. The returned slice holds the error for each event at the same
+// index, nil for those that were written; it is nil if every event was
+// written.
+func (c *Client) PutEvents(events []EventWrite, concurrency int) []error {
+	errs := make([]error, len(events))
+	key := func(i int) string {
+		return buildURI(events[i].Collection, events[i].Key)
+	}
+	parallelByKey(len(events), concurrency, key, func(i int) {
+		e := events[i]
+		errs[i] = c.PutEvent(e.Collection, e.Key, e.Kind, e.Value)
+	})
+
+	for _, err := range errs {
+		if err != nil {
+			return errs
+		}
+	}
+	return nil
 }
 
 // Put an event of the specified type to provided collection-key pair and time.
 func (c *Client) PutEventWithTime(collection, key, kind string, time int64, value interface{}) error {
-	reader, writer := io.Pipe()
-	encoder := json.NewEncoder(writer)
+	buf, err := encodeJSON(value)
+	if err != nil {
+		return err
+	}
 
-	go func() { writer.CloseWithError(encoder.Encode(value)) }()
-	return c.PutEventWithTimeRaw(collection, key, kind, time, reader)
+	return c.PutEventWithTimeRaw(collection, key, kind, time, buf)
 }
 
 // Put an event of the specified type to provided collection-key pair and time.
@@ -74,13 +456,14 @@ func (c *Client) PutEventWithTimeRaw(collection, key, kind string, time int64, v
 		"timestamp": []string{strconv.FormatInt(time, 10)},
 	}
 
-	trailingUri := collection + "/" + key + "/events/" + kind + "?" + queryVariables.Encode()
+	trailingUri := buildURI(collection, key, "events", kind) + "?" + queryVariables.Encode()
 
-	return c.doPutEvent(trailingUri, value)
+	_, err := c.doPutEvent(trailingUri, nil, value)
+	return err
 }
 
-// Execute event get.
-func (c *Client) doGetEvents(trailingUri string) (*EventResults, error) {
+// Execute event get, tagging the returned events with their kind.
+func (c *Client) doGetEvents(trailingUri, kind string) (*EventResults, error) {
 	resp, err := c.doRequest("GET", trailingUri, nil, nil)
 
 	if err != nil {
@@ -93,31 +476,97 @@ func (c *Client) doGetEvents(trailingUri string) (*EventResults, error) {
 		return nil, newError(resp)
 	}
 
-	decoder := json.NewDecoder(resp.Body)
 	results := new(EventResults)
-	if err = decoder.Decode(results); err != nil {
+	if err = decodeResponse(resp, results, "event results"); err != nil {
 		return nil, err
 	}
 
+	for i := range results.Results {
+		results.Results[i].Kind = kind
+	}
+
 	return results, err
 }
 
-// Execute event put.
-func (c *Client) doPutEvent(trailingUri string, value io.Reader) error {
-	resp, err := c.doRequest("PUT", trailingUri, nil, value)
+// Execute event put, returning the response headers.
+func (c *Client) doPutEvent(trailingUri string, headers map[string]string, value io.Reader) (http.Header, error) {
+	resp, err := c.doRequest("PUT", trailingUri, headers, value)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 204 {
-		return newError(resp)
+		return nil, newError(resp)
 	}
-	return nil
+	return resp.Header, nil
+}
+
+// Builds the trailing URI of a single event.
+func eventURI(collection, key, kind string, timestamp int64, ordinal uint64) string {
+	return buildURI(collection, key, "events", kind,
+		strconv.FormatInt(timestamp, 10), strconv.FormatUint(ordinal, 10))
+}
+
+// Extracts the timestamp and ordinal from an event's Location header, which
+// has the form /v0/collection/key/events/kind/timestamp/ordinal, resolved
+// against roots as locationSegments does.
+func parseEventLocation(location string, roots ...string) (int64, uint64, error) {
+	segments, err := locationSegments(location, roots...)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if len(segments) != 6 || segments[2] != "events" {
+		return 0, 0, fmt.Errorf("unexpected event location %q", location)
+	}
+
+	timestamp, err := strconv.ParseInt(segments[4], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid timestamp in event location %q: %v", location, err)
+	}
+
+	ordinal, err := strconv.ParseUint(segments[5], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid ordinal in event location %q: %v", location, err)
+	}
+
+	return timestamp, ordinal, nil
+}
+
+// Converts a time to the milliseconds since the epoch that Orchestrate uses
+// for event timestamps.
+func millis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// Check if there is a subsequent page of event results.
+func (r *EventResults) HasNext() bool {
+	return r.Next != ""
 }
 
 // Marshall the value of an event into the provided object.
 func (r *Event) Value(value interface{}) error {
 	return json.Unmarshal(r.RawValue, value)
 }
+
+// Returns a copy of the raw JSON value of an event.
+func (r *Event) Raw() []byte {
+	return append([]byte(nil), r.RawValue...)
+}
+
+// Returns the size in bytes of the raw JSON value of an event.
+func (r *Event) Size() int {
+	return len(r.RawValue)
+}
+
+// Returns the position of an event, for use in EventListOptions.
+func (r *Event) Position() string {
+	return EventPosition(int64(r.Timestamp), r.Ordinal)
+}
+
+// Returns the time of an event, converted from its Timestamp.
+func (r *Event) Time() time.Time {
+	return time.Unix(0, int64(r.Timestamp)*int64(time.Millisecond))
+}
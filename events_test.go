@@ -0,0 +1,358 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseEventLocation(t *testing.T) {
+	timestamp, ordinal, err := parseEventLocation("/v0/users/bob/events/login/1398286518286/6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if timestamp != 1398286518286 || ordinal != 6 {
+		t.Errorf("got (%d, %d), expected (1398286518286, 6)", timestamp, ordinal)
+	}
+
+	timestamp, ordinal, err = parseEventLocation("https://api.orchestrate.io/v0/users/bob/events/login/12/3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if timestamp != 12 || ordinal != 3 {
+		t.Errorf("got (%d, %d), expected (12, 3)", timestamp, ordinal)
+	}
+}
+
+func TestPutEventAndLocateBaseURL(t *testing.T) {
+	c := NewClientWithOptions("token",
+		WithBaseURL("https://proxy.example.com/orchestrate/v1/"),
+		WithTransport(serve(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Location", "/orchestrate/v1/users/bob/events/login/12/3")
+			w.WriteHeader(204)
+		})))
+
+	timestamp, ordinal, err := c.PutEventAndLocate("users", "bob", "login", map[string]int{"n": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if timestamp != 12 || ordinal != 3 {
+		t.Errorf("got (%d, %d), expected (12, 3)", timestamp, ordinal)
+	}
+}
+
+func TestParseEventLocationMalformed(t *testing.T) {
+	for _, location := range []string{
+		"",
+		"/v0/users/bob",
+		"/v0/users/bob/events/login/12",
+		"/v0/users/bob/refs/login/12/3",
+		"/v0/users/bob/events/login/abc/3",
+		"/v0/users/bob/events/login/12/-1",
+		"/v1/users/bob/events/login/12/3",
+	} {
+		if _, _, err := parseEventLocation(location); err == nil {
+			t.Errorf("expected an error parsing %q", location)
+		}
+	}
+}
+
+func TestGetEventsMultiKind(t *testing.T) {
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		if limit := r.URL.Query().Get("limit"); limit != "3" {
+			t.Errorf("expected limit=3, got %q", limit)
+		}
+		switch r.URL.Path {
+		case "/v0/users/bob/events/login":
+			fmt.Fprint(w, `{"count":2,"results":[{"timestamp":30,"ordinal":1},{"timestamp":10,"ordinal":1}]}`)
+		case "/v0/users/bob/events/purchase":
+			fmt.Fprint(w, `{"count":2,"results":[{"timestamp":30,"ordinal":2},{"timestamp":20,"ordinal":1}]}`)
+		default:
+			t.Errorf("unexpected request for %s", r.URL.Path)
+		}
+	}))
+
+	results, err := c.GetEventsMultiKind("users", "bob", []string{"login", "purchase"}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Event{
+		{Timestamp: 30, Ordinal: 2, Kind: "purchase"},
+		{Timestamp: 30, Ordinal: 1, Kind: "login"},
+		{Timestamp: 20, Ordinal: 1, Kind: "purchase"},
+	}
+	if results.Count != 3 || len(results.Results) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(results.Results))
+	}
+	for i, event := range results.Results {
+		if event.Timestamp != expected[i].Timestamp || event.Ordinal != expected[i].Ordinal || event.Kind != expected[i].Kind {
+			t.Errorf("event %d: got %+v, expected %+v", i, event, expected[i])
+		}
+	}
+}
+
+func TestGetEventsTruncatedResponse(t *testing.T) {
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"count":2,"results":[{"timestamp":30,"ordinal":1},{"times`)
+	}))
+
+	results, err := c.GetEvents("users", "bob", "login")
+	if results != nil {
+		t.Errorf("expected no results from a truncated response, got %+v", results)
+	}
+	if err == nil || !strings.Contains(err.Error(), "event results") {
+		t.Fatalf("expected an error describing the truncated events, got %v", err)
+	}
+}
+
+func TestGetEventsBetween(t *testing.T) {
+	start := time.Date(2014, 4, 23, 21, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("start") != "1398286800000" || query.Get("end") != "1398290400000" || query.Get("limit") != "10" {
+			t.Errorf("unexpected query %q", r.URL.RawQuery)
+		}
+		fmt.Fprint(w, `{"count":1,"results":[{"ordinal":1,"timestamp":1398286818286,"value":{}}]}`)
+	}))
+
+	results, err := c.GetEventsBetween("users", "bob", "login", start, end, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results.Results) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(results.Results))
+	}
+
+	expected := start.Add(18286 * time.Millisecond)
+	if actual := results.Results[0].Time(); !actual.Equal(expected) {
+		t.Errorf("expected the event time %v, got %v", expected, actual)
+	}
+}
+
+func TestEventRef(t *testing.T) {
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"count":1,"results":[{"path":{"collection":"users","key":"bob","ref":"82eafab14dc84ed3","kind":"login"},`+
+			`"ordinal":6,"timestamp":1398286518286,"value":{"ip":"10.0.0.1"}}]}`)
+	}))
+
+	results, err := c.GetEvents("users", "bob", "login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := results.Results[0]
+	if event.Ref != "82eafab14dc84ed3" || event.Ordinal != 6 || event.Timestamp != 1398286518286 || event.Kind != "login" {
+		t.Errorf("unexpected event %+v", event)
+	}
+	if string(event.RawValue) != `{"ip":"10.0.0.1"}` {
+		t.Errorf("unexpected value %s", event.RawValue)
+	}
+}
+
+func TestGetEventsStream(t *testing.T) {
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		if limit := r.URL.Query().Get("limit"); limit != "3" {
+			t.Errorf("expected a limit of 3, got %q", limit)
+		}
+		fmt.Fprint(w, `{"count":3,"results":[`+
+			`{"ordinal":3,"timestamp":3,"value":{"n":3}},`+
+			`{"ordinal":2,"timestamp":2,"value":{"n":2}},`+
+			`{"ordinal":1,"timestamp":1,"value":{"n":1}}],"next":"/v0/users/bob/events/login?limit=3&beforeEvent=1/1"}`)
+	}))
+
+	var ordinals []uint64
+	err := c.GetEventsStream("users", "bob", "login", 3, func(e Event) error {
+		if e.Kind != "login" {
+			t.Errorf("expected events of kind login, got %q", e.Kind)
+		}
+		ordinals = append(ordinals, e.Ordinal)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(ordinals) != "[3 2 1]" {
+		t.Errorf("unexpected events %v", ordinals)
+	}
+
+	stop := fmt.Errorf("stop")
+	ordinals = nil
+	err = c.GetEventsStream("users", "bob", "login", 3, func(e Event) error {
+		ordinals = append(ordinals, e.Ordinal)
+		return stop
+	})
+	if err != stop || len(ordinals) != 1 {
+		t.Errorf("expected the callback's error to stop the stream, got %v after %v", err, ordinals)
+	}
+}
+
+func TestGetEventsStreamHoldsSlot(t *testing.T) {
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"count":1,"results":[{"ordinal":1,"timestamp":1,"value":{}}]}`)
+	}))
+	c.SetMaxConcurrency(1)
+
+	err := c.GetEventsStream("users", "bob", "login", 1, func(e Event) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if _, err := c.WithContext(ctx).Get("users", "alice"); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected a request from the callback to wait for the stream's slot, got %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPutEventsOrder(t *testing.T) {
+	var mu sync.Mutex
+	written := map[string][]string{}
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		written[r.URL.Path] = append(written[r.URL.Path], strings.TrimSpace(string(body)))
+		mu.Unlock()
+		w.WriteHeader(204)
+	}))
+
+	var events []EventWrite
+	for i := 0; i < 20; i++ {
+		for _, key := range []string{"alice", "bob", "carol"} {
+			events = append(events, EventWrite{"users", key, "step", i})
+		}
+	}
+
+	if errs := c.PutEvents(events, 4); errs != nil {
+		t.Fatalf("unexpected errors %v", errs)
+	}
+
+	for path, values := range written {
+		for i, value := range values {
+			if value != fmt.Sprint(i) {
+				t.Fatalf("%s: expected the events in order, got %v", path, values)
+			}
+		}
+	}
+	if len(written) != 3 {
+		t.Errorf("expected events for 3 keys, got %d", len(written))
+	}
+}
+
+func TestGetEvent(t *testing.T) {
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v0/users/bob/events/login/1400000000123/7" {
+			w.WriteHeader(404)
+			fmt.Fprint(w, `{"message":"not found"}`)
+			return
+		}
+		w.Header().Set("ETag", `"82eafab14dc84ed3"`)
+		fmt.Fprint(w, `{"path":{"collection":"users","key":"bob","ref":"82eafab14dc84ed3"},`+
+			`"timestamp":1400000000123,"ordinal":7,"value":{"ip":"10.0.0.1"}}`)
+	}))
+
+	event, err := c.GetEvent("users", "bob", "login", 1400000000123, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Kind != "login" || event.Ref != "82eafab14dc84ed3" || event.Timestamp != 1400000000123 ||
+		event.Ordinal != 7 || string(event.RawValue) != `{"ip":"10.0.0.1"}` {
+		t.Errorf("unexpected event %+v", event)
+	}
+
+	if _, err := c.GetEvent("users", "bob", "login", 1400000000123, 8); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestUpdateAndDeleteEvent(t *testing.T) {
+	const uri = "/v0/users/bob/events/login/1400000000123/7"
+	var requests []string
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.RequestURI())
+		if r.URL.Path != uri {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if match := r.Header.Get("If-Match"); match != "" && match != `"r1"` {
+			w.WriteHeader(412)
+			fmt.Fprint(w, `{"message":"ref does not match"}`)
+			return
+		}
+		if r.Method == "PUT" {
+			if body, _ := ioutil.ReadAll(r.Body); string(body) != `{"ip":"10.0.0.2"}`+"\n" {
+				t.Errorf("unexpected body %q", body)
+			}
+			w.Header().Set("ETag", `"r2"`)
+		}
+		w.WriteHeader(204)
+	}))
+
+	value := map[string]string{"ip": "10.0.0.2"}
+	ref, err := c.UpdateEvent("users", "bob", "login", 1400000000123, 7, value)
+	if err != nil || ref != "r2" {
+		t.Errorf("expected the new ref r2, got %q, %v", ref, err)
+	}
+
+	if _, err := c.UpdateEventIfUnmodified("users", "bob", "login", 1400000000123, 7, "r0", value); !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("expected ErrPreconditionFailed, got %v", err)
+	}
+	if err := c.DeleteEventIfUnmodified("users", "bob", "login", 1400000000123, 7, "r0"); !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("expected ErrPreconditionFailed, got %v", err)
+	}
+	if err := c.DeleteEvent("users", "bob", "login", 1400000000123, 7); err != nil {
+		t.Fatal(err)
+	}
+
+	if last := requests[len(requests)-1]; last != "DELETE "+uri+"?purge=true" {
+		t.Errorf("expected the event to be purged, got %s", last)
+	}
+}
+
+func TestGetEventsPage(t *testing.T) {
+	var query string
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		fmt.Fprint(w, `{"count":0,"results":[]}`)
+	}))
+
+	opts := EventListOptions{Limit: 10, BeforeEvent: EventPosition(1400000000123, 7), AfterEvent: "1300000000000"}
+	if _, err := c.GetEventsPage("users", "bob", "login", opts); err != nil {
+		t.Fatal(err)
+	}
+	if query != "afterEvent=1300000000000&beforeEvent=1400000000123%2F7&limit=10" {
+		t.Errorf("unexpected query %q", query)
+	}
+}
+
+func TestCreateEvent(t *testing.T) {
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/v0/users/bob/events/login" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("unexpected content type %q", ct)
+		}
+		w.Header().Set("Location", "/v0/users/bob/events/login/1400000000123/7")
+		w.Header().Set("ETag", `"82eafab14dc84ed3"`)
+		w.WriteHeader(201)
+	}))
+
+	event, err := c.CreateEvent("users", "bob", "login", map[string]string{"ip": "10.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Timestamp != 1400000000123 || event.Ordinal != 7 || event.Ref != "82eafab14dc84ed3" ||
+		event.Kind != "login" || string(event.RawValue) != `{"ip":"10.0.0.1"}` {
+		t.Errorf("unexpected event %+v", event)
+	}
+}
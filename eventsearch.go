@@ -0,0 +1,154 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+import (
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Holds results returned from a search of events.
+type EventSearchResults struct {
+	Count      uint64              `json:"count"`
+	TotalCount uint64              `json:"total_count"`
+	Results    []EventSearchResult `json:"results"`
+	Next       Cursor              `json:"next,omitempty"`
+	Prev       Cursor              `json:"prev,omitempty"`
+}
+
+// An individual event found by a search.
+type EventSearchResult struct {
+	// The collection-key the event belongs to, and the ref of its value.
+	Path Path `json:"path"`
+
+	// The type of the event, and where it lies in the key's history.
+	Kind      string `json:"kind"`
+	Timestamp uint64 `json:"timestamp"`
+	Ordinal   uint64 `json:"ordinal"`
+
+	Score    float64         `json:"score"`
+	RawValue json.RawMessage `json:"value"`
+}
+
+// Search the events of every key in a collection with a Lucene Query Parser
+// Syntax Query, as Search does for values. The query is restricted to events
+// by adding @path.kind:event to it; an event's type can be matched with
+// @path.type, as in "@path.type:activity AND value.action:login".
+func (c *Client) SearchEvents(collection, query string, limit, offset int) (*EventSearchResults, error) {
+	queryVariables := url.Values{
+		"query":  []string{"@path.kind:event AND (" + query + ")"},
+		"limit":  []string{strconv.Itoa(limit)},
+		"offset": []string{strconv.Itoa(offset)},
+	}
+
+	trailingUri := buildURI(collection) + "?" + queryVariables.Encode()
+
+	return c.doSearchEvents(trailingUri)
+}
+
+// Get the page of event search results that follow that provided set.
+func (c *Client) SearchEventsGetNext(results *EventSearchResults) (*EventSearchResults, error) {
+	next := new(EventSearchResults)
+	if err := c.Follow(results.Next, next); err != nil {
+		return nil, err
+	}
+	return next, nil
+}
+
+// Get the page of event search results that precede that provided set.
+func (c *Client) SearchEventsGetPrev(results *EventSearchResults) (*EventSearchResults, error) {
+	prev := new(EventSearchResults)
+	if err := c.Follow(results.Prev, prev); err != nil {
+		return nil, err
+	}
+	return prev, nil
+}
+
+// Execute an event search request.
+func (c *Client) doSearchEvents(trailingUri string) (*EventSearchResults, error) {
+	resp, err := c.doRequest("GET", trailingUri, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, newError(resp)
+	}
+
+	result := new(EventSearchResults)
+	if err := decodeResponse(resp, result, "event search results"); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Decodes an event search result, taking the type, timestamp and ordinal of
+// the event from its path when they are not listed beside it.
+func (r *EventSearchResult) UnmarshalJSON(data []byte) error {
+	type result EventSearchResult
+	var decoded struct {
+		result
+		Path struct {
+			Path
+			Type      string `json:"type"`
+			Timestamp uint64 `json:"timestamp"`
+			Ordinal   uint64 `json:"ordinal"`
+		} `json:"path"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	*r = EventSearchResult(decoded.result)
+	r.Path = decoded.Path.Path
+	if r.Kind == "" {
+		r.Kind = decoded.Path.Type
+	}
+	if r.Timestamp == 0 {
+		r.Timestamp = decoded.Path.Timestamp
+	}
+	if r.Ordinal == 0 {
+		r.Ordinal = decoded.Path.Ordinal
+	}
+	return nil
+}
+
+// Check if there is a subsequent page of event search results.
+func (r *EventSearchResults) HasNext() bool {
+	return r.Next != ""
+}
+
+// Check if there is a previous page of event search results.
+func (r *EventSearchResults) HasPrev() bool {
+	return r.Prev != ""
+}
+
+// Returns the event search results as Results. Each refers to an element of
+// r.Results rather than a copy.
+func (r *EventSearchResults) Items() []Result {
+	items := make([]Result, len(r.Results))
+	for i := range r.Results {
+		items[i] = &r.Results[i]
+	}
+	return items
+}
+
+// Marshall the value of an EventSearchResult into the provided object.
+func (r *EventSearchResult) Value(value interface{}) error {
+	return json.Unmarshal(r.RawValue, value)
+}
+
+// Returns a copy of the raw JSON value of an EventSearchResult.
+func (r *EventSearchResult) Raw() []byte {
+	return append([]byte(nil), r.RawValue...)
+}
+
+// Returns the time of the event.
+func (r *EventSearchResult) Time() time.Time {
+	return time.Unix(0, int64(r.Timestamp)*int64(time.Millisecond))
+}
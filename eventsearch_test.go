@@ -0,0 +1,49 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestSearchEvents(t *testing.T) {
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("offset") == "1" {
+			fmt.Fprint(w, `{"count":0,"total_count":1,"results":[],"prev":"/v0/users?query=x&limit=1&offset=0"}`)
+			return
+		}
+		if query := r.URL.Query().Get("query"); query != "@path.kind:event AND (value.action:login)" {
+			t.Errorf("unexpected query %q", query)
+		}
+		fmt.Fprint(w, `{"count":1,"total_count":2,"results":[{"path":{"collection":"users","key":"bob",`+
+			`"ref":"r1","kind":"event","type":"activity","timestamp":1400000000123,"ordinal":7},`+
+			`"score":2.5,"value":{"action":"login"}}],"next":"/v0/users?query=x&limit=1&offset=1"}`)
+	}))
+
+	results, err := c.SearchEvents("users", "value.action:login", 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results.TotalCount != 2 || len(results.Results) != 1 {
+		t.Fatalf("unexpected results %+v", results)
+	}
+
+	event := results.Results[0]
+	if event.Path.Key != "bob" || event.Path.Ref != "r1" || event.Kind != "activity" ||
+		event.Timestamp != 1400000000123 || event.Ordinal != 7 || event.Score != 2.5 {
+		t.Errorf("unexpected event %+v", event)
+	}
+	if event.Time().UnixNano() != 1400000000123*1000000 {
+		t.Errorf("unexpected time %v", event.Time())
+	}
+
+	next, err := c.SearchEventsGetNext(results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.HasNext() || !next.HasPrev() {
+		t.Errorf("unexpected second page %+v", next)
+	}
+}
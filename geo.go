@@ -0,0 +1,64 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// A geospatial search query over a field holding a location, an object with
+// "latitude" and "longitude" (or "lat" and "lon") members. Its String is the
+// query to pass to Search, and it can be combined with other queries using
+// Lucene's AND and OR.
+type GeoQuery struct {
+	field  string
+	clause string
+}
+
+// Returns a query matching the values whose location field lies within the
+// given distance of a point. The distance is a number followed by a unit, as
+// in "500m", "1.5km" or "10mi".
+func Near(field string, lat, lon float64, distance string) GeoQuery {
+	return GeoQuery{
+		field:  field,
+		clause: fmt.Sprintf("NEAR:{lat:%s lon:%s dist:%s}", formatCoordinate(lat), formatCoordinate(lon), escapeQuery(distance)),
+	}
+}
+
+// Returns a query matching the values whose location field lies within the
+// bounding box with the given edges, in degrees.
+func WithinBox(field string, north, east, south, west float64) GeoQuery {
+	return GeoQuery{
+		field: field,
+		clause: fmt.Sprintf("IN:{north:%s east:%s south:%s west:%s}",
+			formatCoordinate(north), formatCoordinate(east), formatCoordinate(south), formatCoordinate(west)),
+	}
+}
+
+// Returns the query in the Lucene syntax Search expects. The field is escaped,
+// so it may contain characters special to the syntax.
+func (q GeoQuery) String() string {
+	return escapeQuery(q.field) + ":" + q.clause
+}
+
+// Returns the sort expression that orders the matches of a Near query by their
+// distance from its point, nearest first, for use with SearchSorted. Sort
+// expressions are not Lucene queries, so the field is given as it is.
+func (q GeoQuery) ByDistance() string {
+	return q.field + ":distance:asc"
+}
+
+// Formats a coordinate in as few digits as represent it exactly.
+func formatCoordinate(degrees float64) string {
+	return strconv.FormatFloat(degrees, 'f', -1, 64)
+}
+
+// Search a collection for values whose location field lies within the given
+// distance of a point, nearest first. The distance is a number followed by a
+// unit, as in "500m", "1.5km" or "10mi". See Near.
+func (c *Client) SearchNear(collection, field string, lat, lon float64, distance string, limit, offset int) (*SearchResults, error) {
+	query := Near(field, lat, lon, distance)
+
+	return c.SearchSorted(collection, query.String(), query.ByDistance(), limit, offset)
+}
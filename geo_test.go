@@ -0,0 +1,46 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestGeoQuery(t *testing.T) {
+	tests := []struct {
+		query    GeoQuery
+		expected string
+	}{
+		{Near("value.location", 45.5, -122.25, "1.5km"), `value.location:NEAR:{lat:45.5 lon:-122.25 dist:1.5km}`},
+		{WithinBox("value.location", 46, -122, 45, -123.125), `value.location:IN:{north:46 east:-122 south:45 west:-123.125}`},
+		{Near("value.home base", 0, 0, "10 mi"), `value.home\ base:NEAR:{lat:0 lon:0 dist:10\ mi}`},
+	}
+
+	for _, test := range tests {
+		if query := test.query.String(); query != test.expected {
+			t.Errorf("expected %s, got %s", test.expected, query)
+		}
+	}
+}
+
+func TestGeoQueryByDistance(t *testing.T) {
+	if sort := Near("value.loc-x", 0, 0, "1km").ByDistance(); sort != "value.loc-x:distance:asc" {
+		t.Errorf("expected the field to be left unescaped, got %s", sort)
+	}
+}
+
+func TestSearchNear(t *testing.T) {
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("query") != "value.location:NEAR:{lat:1 lon:2 dist:5km}" || query.Get("sort") != "value.location:distance:asc" {
+			t.Errorf("unexpected query %s", r.URL.RawQuery)
+		}
+		fmt.Fprint(w, `{"count":0,"results":[]}`)
+	}))
+
+	if _, err := c.SearchNear("places", "value.location", 1, 2, "5km", 10, 0); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -3,14 +3,33 @@
 package gorc
 
 import (
+	"bytes"
 	"encoding/json"
-	"strings"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strconv"
 )
 
+var (
+	// The maximum number of requests PathExists will make before giving up.
+	MaxPathExpansion = 1000
+
+	// Returned by PathExists when it gives up after making MaxPathExpansion
+	// requests.
+	ErrTraversalLimit = errors.New("graph traversal limit reached")
+)
+
+// The number of relations PathExists reads with each request.
+const pathPageSize = 100
+
 // Holds results returned from a Graph query.
 type GraphResults struct {
 	Count   uint64        `json:"count"`
 	Results []GraphResult `json:"results"`
+	Next    Cursor        `json:"next,omitempty"`
+	Prev    Cursor        `json:"prev,omitempty"`
 }
 
 // An individual graph result.
@@ -21,9 +40,58 @@ type GraphResult struct {
 
 // Get all related key/value objects by collection-key and a list of relations.
 func (c *Client) GetRelations(collection, key string, hops []string) (*GraphResults, error) {
-	relationsPath := strings.Join(hops, "/")
+	trailingUri := buildURI(append([]string{collection, key, "relations"}, hops...)...)
+
+	return c.doGetRelations(trailingUri)
+}
+
+// Get a page of the key/value objects directly related to a collection-key by
+// relations of a single kind.
+func (c *Client) GetRelationsOfKind(collection, key, kind string, limit, offset int) (*GraphResults, error) {
+	queryVariables := url.Values{
+		"limit":  []string{strconv.Itoa(limit)},
+		"offset": []string{strconv.Itoa(offset)},
+	}
+
+	trailingUri := buildURI(collection, key, "relations", kind) + "?" + queryVariables.Encode()
 
-	trailingUri := collection + "/" + key + "/relations/" + relationsPath
+	return c.doGetRelations(trailingUri)
+}
+
+// Get a page of the key/value objects related to a collection-key by a list of
+// relations, as GetRelations does, with the specified size limit and offset.
+// Subsequent pages can be fetched with GetRelationsNext.
+func (c *Client) GetRelationsPage(collection, key string, hops []string, limit, offset int) (*GraphResults, error) {
+	queryVariables := url.Values{
+		"limit":  []string{strconv.Itoa(limit)},
+		"offset": []string{strconv.Itoa(offset)},
+	}
+
+	trailingUri := buildURI(append([]string{collection, key, "relations"}, hops...)...) + "?" + queryVariables.Encode()
+
+	return c.doGetRelations(trailingUri)
+}
+
+// Get the page of graph results that follow that provided set.
+func (c *Client) GetRelationsNext(results *GraphResults) (*GraphResults, error) {
+	next := new(GraphResults)
+	if err := c.Follow(results.Next, next); err != nil {
+		return nil, err
+	}
+	return next, nil
+}
+
+// Get the page of graph results that precede that provided set.
+func (c *Client) GetRelationsPrev(results *GraphResults) (*GraphResults, error) {
+	prev := new(GraphResults)
+	if err := c.Follow(results.Prev, prev); err != nil {
+		return nil, err
+	}
+	return prev, nil
+}
+
+// Execute a relations get.
+func (c *Client) doGetRelations(trailingUri string) (*GraphResults, error) {
 	resp, err := c.doRequest("GET", trailingUri, nil, nil)
 	if err != nil {
 		return nil, err
@@ -35,18 +103,109 @@ func (c *Client) GetRelations(collection, key string, hops []string) (*GraphResu
 		return nil, newError(resp)
 	}
 
-	decoder := json.NewDecoder(resp.Body)
 	result := new(GraphResults)
-	if err := decoder.Decode(result); err != nil {
+	if err := decodeResponse(resp, result, "graph results"); err != nil {
 		return nil, err
 	}
 
 	return result, nil
 }
 
+// Reports whether the sink collection-key can be reached from the source
+// collection-key by following at most maxHops relations of the given kinds.
+// Orchestrate only lists relations by kind, so the kinds to follow must be
+// named. The search is breadth first and stops as soon as the sink is found.
+// Every page of each key's relations is read, and if the search would need
+// more than MaxPathExpansion requests it gives up and returns
+// ErrTraversalLimit.
+func (c *Client) PathExists(sourceCollection, sourceKey, sinkCollection, sinkKey string, kinds []string, maxHops int) (bool, error) {
+	source := Path{Collection: sourceCollection, Key: sourceKey}
+	sink := Path{Collection: sinkCollection, Key: sinkKey}
+	if source == sink {
+		return true, nil
+	}
+
+	visited := map[Path]bool{source: true}
+	frontier := []Path{source}
+	requests := 0
+
+	for hop := 0; hop < maxHops && len(frontier) > 0; hop++ {
+		var next []Path
+		for _, node := range frontier {
+			for _, kind := range kinds {
+				var results *GraphResults
+				for {
+					if requests >= MaxPathExpansion {
+						return false, ErrTraversalLimit
+					}
+					requests++
+
+					var err error
+					if results == nil {
+						results, err = c.GetRelationsPage(node.Collection, node.Key, []string{kind}, pathPageSize, 0)
+					} else {
+						results, err = c.GetRelationsNext(results)
+					}
+					if err != nil {
+						return false, err
+					}
+
+					for _, result := range results.Results {
+						neighbor := Path{Collection: result.Path.Collection, Key: result.Path.Key}
+						if neighbor == sink {
+							return true, nil
+						}
+						if !visited[neighbor] {
+							visited[neighbor] = true
+							next = append(next, neighbor)
+						}
+					}
+
+					if !results.HasNext() {
+						break
+					}
+				}
+			}
+		}
+		frontier = next
+	}
+
+	return false, nil
+}
+
+// A relationship of a specified type from a source collection-key to a sink
+// collection-key.
+type Edge struct {
+	SourceCollection string
+	SourceKey        string
+	Kind             string
+	SinkCollection   string
+	SinkKey          string
+}
+
+// Create many relationships with PutRelation, making at most concurrency
+// requests at once. The edges are written in no particular order and a
+// failure does not stop the others from being written. The returned slice
+// holds the error for each edge at the same index, nil for those that were
+// created; it is nil if every edge was created.
+func (c *Client) PutRelations(edges []Edge, concurrency int) []error {
+	errs := make([]error, len(edges))
+	parallel(len(edges), concurrency, func(i int) {
+		e := edges[i]
+		errs[i] = c.PutRelation(e.SourceCollection, e.SourceKey, e.Kind, e.SinkCollection, e.SinkKey)
+	})
+
+	for _, err := range errs {
+		if err != nil {
+			return errs
+		}
+	}
+	return nil
+}
+
 // Create a relationship of a specified type between two collection-keys.
 func (c *Client) PutRelation(sourceCollection, sourceKey, kind, sinkCollection, sinkKey string) error {
-	trailingUri := sourceCollection + "/" + sourceKey + "/relation/" + kind + "/" + sinkCollection + "/" + sinkKey
+	trailingUri := buildURI(sourceCollection, sourceKey, "relation", kind, sinkCollection, sinkKey)
 	resp, err := c.doRequest("PUT", trailingUri, nil, nil)
 	if err != nil {
 		return err
@@ -61,9 +220,171 @@ func (c *Client) PutRelation(sourceCollection, sourceKey, kind, sinkCollection,
 	return nil
 }
 
-// Create a relationship of a specified type between two collection-keys.
+// Check whether a relationship of a specified type exists between two
+// collection-keys.
+func (c *Client) RelationExists(sourceCollection, sourceKey, kind, sinkCollection, sinkKey string) (bool, error) {
+	trailingUri := buildURI(sourceCollection, sourceKey, "relation", kind, sinkCollection, sinkKey)
+	resp, err := c.doRequest("GET", trailingUri, nil, nil)
+	if err != nil {
+		return false, err
+	}
+
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case 200:
+		return true, nil
+	case 404:
+		return false, nil
+	}
+
+	return false, newError(resp)
+}
+
+// Create a relationship of a specified type between two collection-keys,
+// reporting whether it is new. Orchestrate answers the same way whether or not
+// the relationship already existed, so this checks with RelationExists first
+// and only writes a relationship that is missing. A relationship created by
+// another writer between the check and the write is reported as new.
+func (c *Client) PutRelationOK(sourceCollection, sourceKey, kind, sinkCollection, sinkKey string) (bool, error) {
+	exists, err := c.RelationExists(sourceCollection, sourceKey, kind, sinkCollection, sinkKey)
+	if err != nil || exists {
+		return false, err
+	}
+
+	if err := c.PutRelation(sourceCollection, sourceKey, kind, sinkCollection, sinkKey); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Create a relationship of a specified type between two collection-keys,
+// carrying a value as its properties, only if it does not already exist.
+// Reports whether it was created; an existing relationship is left as it is
+// and is not an error.
+func (c *Client) PutRelationIfAbsent(sourceCollection, sourceKey, kind, sinkCollection, sinkKey string, value interface{}) (bool, error) {
+	buf, err := encodeJSON(value)
+	if err != nil {
+		return false, err
+	}
+
+	headers := map[string]string{
+		"If-None-Match": "\"*\"",
+	}
+
+	trailingUri := buildURI(sourceCollection, sourceKey, "relation", kind, sinkCollection, sinkKey)
+	resp, err := c.doRequest("PUT", trailingUri, headers, buf)
+	if err != nil {
+		return false, err
+	}
+
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case 200, 201, 204:
+		return true, nil
+	case 412:
+		return false, nil
+	}
+
+	return false, newError(resp)
+}
+
+// Create a relationship of a specified type in both directions between two
+// collection-keys, for relationships that are symmetric, such as friendship.
+// Either direction that already exists is left as it is, keeping its
+// properties. If the second direction can not be created, the first is
+// deleted again, unless it already existed, and the error is returned. The
+// writes are separate requests, so other clients may see one direction before
+// the other.
+func (c *Client) PutRelationBoth(collection1, key1, kind, collection2, key2 string) error {
+	created, err := c.PutRelationOK(collection1, key1, kind, collection2, key2)
+	if err != nil {
+		return err
+	}
+
+	if _, err := c.PutRelationOK(collection2, key2, kind, collection1, key1); err != nil {
+		if created {
+			if rerr := c.DeleteRelation(collection1, key1, kind, collection2, key2); rerr != nil {
+				return fmt.Errorf("%w; rolling back failed: %v", err, rerr)
+			}
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Delete a relationship of a specified type in both directions between two
+// collection-keys. If the second direction can not be deleted, the first is
+// created again with the properties it had and the error is returned.
+func (c *Client) DeleteRelationBoth(collection1, key1, kind, collection2, key2 string) error {
+	properties, err := c.relationProperties(collection1, key1, kind, collection2, key2)
+	if err != nil {
+		return err
+	}
+
+	if err := c.DeleteRelation(collection1, key1, kind, collection2, key2); err != nil {
+		return err
+	}
+
+	if err := c.DeleteRelation(collection2, key2, kind, collection1, key1); err != nil {
+		if rerr := c.putRelationProperties(collection1, key1, kind, collection2, key2, properties); rerr != nil {
+			return fmt.Errorf("%w; rolling back failed: %v", err, rerr)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Reads the raw properties of a relationship, or nil if it does not exist.
+func (c *Client) relationProperties(sourceCollection, sourceKey, kind, sinkCollection, sinkKey string) ([]byte, error) {
+	trailingUri := buildURI(sourceCollection, sourceKey, "relation", kind, sinkCollection, sinkKey)
+	resp, err := c.doRequest("GET", trailingUri, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case 200:
+		return ioutil.ReadAll(resp.Body)
+	case 404:
+		return nil, nil
+	}
+
+	return nil, newError(resp)
+}
+
+// Creates a relationship carrying raw properties, or none if properties is
+// empty.
+func (c *Client) putRelationProperties(sourceCollection, sourceKey, kind, sinkCollection, sinkKey string, properties []byte) error {
+	if len(properties) == 0 {
+		return c.PutRelation(sourceCollection, sourceKey, kind, sinkCollection, sinkKey)
+	}
+
+	trailingUri := buildURI(sourceCollection, sourceKey, "relation", kind, sinkCollection, sinkKey)
+	resp, err := c.doRequest("PUT", trailingUri, nil, bytes.NewReader(properties))
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 204 {
+		return newError(resp)
+	}
+
+	return nil
+}
+
+// Delete a relationship of a specified type between two collection-keys. The
+// relationship is purged, leaving no trace of it.
 func (c *Client) DeleteRelation(sourceCollection string, sourceKey string, kind string, sinkCollection string, sinkKey string) error {
-	trailingUri := sourceCollection + "/" + sourceKey + "/relation/" + kind + "/" + sinkCollection + "/" + sinkKey + "?purge=true"
+	trailingUri := buildURI(sourceCollection, sourceKey, "relation", kind, sinkCollection, sinkKey) + "?purge=true"
 	resp, err := c.doRequest("DELETE", trailingUri, nil, nil)
 
 	if err != nil {
@@ -79,7 +400,32 @@ func (c *Client) DeleteRelation(sourceCollection string, sourceKey string, kind
 	return nil
 }
 
+// Check if there is a subsequent page of graph results.
+func (r *GraphResults) HasNext() bool {
+	return r.Next != ""
+}
+
+// Check if there is a previous page of graph results.
+func (r *GraphResults) HasPrev() bool {
+	return r.Prev != ""
+}
+
 // Marshall the value of a GraphResult into the provided object.
 func (r *GraphResult) Value(value interface{}) error {
 	return json.Unmarshal(r.RawValue, value)
 }
+
+// Returns a copy of the raw JSON value of a GraphResult.
+func (r *GraphResult) Raw() []byte {
+	return append([]byte(nil), r.RawValue...)
+}
+
+// Returns the size in bytes of the raw JSON value of a GraphResult.
+func (r *GraphResult) Size() int {
+	return len(r.RawValue)
+}
+
+// Reports the top level JSON kind of the value of a GraphResult, as ValueKind does.
+func (r *GraphResult) Kind() (string, error) {
+	return ValueKind(r.RawValue)
+}
@@ -0,0 +1,357 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Serves a graph where each user follows the users named in the map, a page
+// of the requested size at a time.
+func serveGraph(t *testing.T, follows map[string][]string) http.RoundTripper {
+	return serve(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/v0/"), "/")
+		if len(parts) != 4 || parts[2] != "relations" || parts[3] != "follows" {
+			t.Errorf("unexpected request for %s", r.URL.Path)
+		}
+
+		keys := follows[parts[1]]
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if offset > len(keys) {
+			offset = len(keys)
+		}
+		end := len(keys)
+		if limit > 0 && offset+limit < end {
+			end = offset + limit
+		}
+
+		var results []string
+		for _, key := range keys[offset:end] {
+			results = append(results, fmt.Sprintf(`{"path":{"collection":"users","key":%q}}`, key))
+		}
+
+		next := ""
+		if end < len(keys) {
+			next = fmt.Sprintf(`,"next":"%s?limit=%d&offset=%d"`, r.URL.Path, limit, end)
+		}
+		fmt.Fprintf(w, `{"count":%d,"results":[%s]%s}`, len(results), strings.Join(results, ","), next)
+	})
+}
+
+func TestPathExists(t *testing.T) {
+	c := newTestClient(serveGraph(t, map[string][]string{
+		"alice": {"bob", "carol"},
+		"bob":   {"alice", "dave"},
+		"dave":  {"erin"},
+	}))
+	follows := []string{"follows"}
+
+	tests := []struct {
+		sink    string
+		maxHops int
+		found   bool
+	}{
+		{"bob", 1, true},
+		{"dave", 1, false},
+		{"dave", 2, true},
+		{"erin", 2, false},
+		{"erin", 3, true},
+		{"frank", 10, false},
+	}
+
+	for _, test := range tests {
+		found, err := c.PathExists("users", "alice", "users", test.sink, follows, test.maxHops)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found != test.found {
+			t.Errorf("PathExists(alice, %s, %d) = %v, expected %v", test.sink, test.maxHops, found, test.found)
+		}
+	}
+}
+
+func TestPathExistsFollowsPages(t *testing.T) {
+	var followed []string
+	for i := 0; i < 2*pathPageSize+1; i++ {
+		followed = append(followed, fmt.Sprintf("user%d", i))
+	}
+	c := newTestClient(serveGraph(t, map[string][]string{"alice": followed}))
+
+	found, err := c.PathExists("users", "alice", "users", followed[len(followed)-1], []string{"follows"}, 1)
+	if err != nil || !found {
+		t.Errorf("expected a sink on the last page to be found, got %v, %v", found, err)
+	}
+
+	defer func(limit int) { MaxPathExpansion = limit }(MaxPathExpansion)
+	MaxPathExpansion = 2
+
+	if _, err := c.PathExists("users", "alice", "users", "nobody", []string{"follows"}, 1); err != ErrTraversalLimit {
+		t.Errorf("expected every page to count against the limit, got %v", err)
+	}
+}
+
+func TestPathExistsLimit(t *testing.T) {
+	c := newTestClient(serveGraph(t, map[string][]string{
+		"a": {"b"},
+		"b": {"c"},
+		"c": {"d"},
+	}))
+
+	defer func(limit int) { MaxPathExpansion = limit }(MaxPathExpansion)
+	MaxPathExpansion = 2
+
+	if _, err := c.PathExists("users", "a", "users", "d", []string{"follows"}, 10); err != ErrTraversalLimit {
+		t.Errorf("expected ErrTraversalLimit, got %v", err)
+	}
+}
+
+func TestGetRelationsOfKind(t *testing.T) {
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v0/users/alice/relations/follows" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if query := r.URL.Query(); query.Get("limit") != "2" || query.Get("offset") != "4" {
+			t.Errorf("unexpected query %q", r.URL.RawQuery)
+		}
+		fmt.Fprint(w, `{"count":1,"results":[{"path":{"collection":"users","key":"bob"}}],`+
+			`"next":"/v0/users/alice/relations/follows?limit=2&offset=6"}`)
+	}))
+
+	results, err := c.GetRelationsOfKind("users", "alice", "follows", 2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !results.HasNext() || len(results.Results) != 1 || results.Results[0].Path.Key != "bob" {
+		t.Errorf("unexpected results %+v", results)
+	}
+}
+
+func TestPutRelationOK(t *testing.T) {
+	edges := map[string]bool{"/v0/users/alice/relation/follows/users/bob": true}
+	puts := 0
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			if !edges[r.URL.Path] {
+				w.WriteHeader(404)
+				fmt.Fprint(w, `{"message":"not found"}`)
+				return
+			}
+			fmt.Fprint(w, `{}`)
+		case "PUT":
+			puts++
+			edges[r.URL.Path] = true
+			w.WriteHeader(204)
+		}
+	}))
+
+	tests := []struct {
+		sink    string
+		created bool
+	}{
+		{"bob", false},
+		{"carol", true},
+		{"carol", false},
+	}
+
+	for _, test := range tests {
+		created, err := c.PutRelationOK("users", "alice", "follows", "users", test.sink)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if created != test.created {
+			t.Errorf("PutRelationOK(%s) = %v, expected %v", test.sink, created, test.created)
+		}
+	}
+	if puts != 1 {
+		t.Errorf("expected only the missing relation to be written, got %d puts", puts)
+	}
+}
+
+func TestPutRelations(t *testing.T) {
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/users/nobody") {
+			w.WriteHeader(404)
+			fmt.Fprint(w, `{"message":"not found"}`)
+			return
+		}
+		w.WriteHeader(204)
+	}))
+
+	edges := []Edge{
+		{"users", "alice", "follows", "users", "bob"},
+		{"users", "alice", "follows", "users", "nobody"},
+		{"users", "bob", "follows", "users", "alice"},
+	}
+
+	errs := c.PutRelations(edges, 2)
+	if len(errs) != len(edges) || errs[0] != nil || errs[1] == nil || errs[2] != nil {
+		t.Errorf("expected only the second edge to fail, got %v", errs)
+	}
+
+	if errs := c.PutRelations(edges[:1], 2); errs != nil {
+		t.Errorf("expected no errors, got %v", errs)
+	}
+}
+
+func TestPutRelationIfAbsent(t *testing.T) {
+	edges := map[string]string{}
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") != `"*"` {
+			t.Errorf("expected If-None-Match: \"*\", got %q", r.Header.Get("If-None-Match"))
+		}
+		if _, ok := edges[r.URL.Path]; ok {
+			w.WriteHeader(412)
+			fmt.Fprint(w, `{"message":"item already exists"}`)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		edges[r.URL.Path] = string(body)
+		w.WriteHeader(204)
+	}))
+
+	created, err := c.PutRelationIfAbsent("users", "alice", "follows", "users", "bob", map[string]int{"since": 2014})
+	if err != nil || !created {
+		t.Fatalf("expected the relation to be created, got %v, %v", created, err)
+	}
+	if body := edges["/v0/users/alice/relation/follows/users/bob"]; body != "{\"since\":2014}\n" {
+		t.Errorf("unexpected properties %q", body)
+	}
+
+	created, err = c.PutRelationIfAbsent("users", "alice", "follows", "users", "bob", map[string]int{"since": 2015})
+	if err != nil || created {
+		t.Errorf("expected the existing relation to be left alone, got %v, %v", created, err)
+	}
+}
+
+func TestDeleteRelation(t *testing.T) {
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "DELETE" || r.URL.RequestURI() != "/v0/users/alice/relation/follows/users/bob?purge=true" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.RequestURI())
+		}
+		w.WriteHeader(204)
+	}))
+
+	if err := c.DeleteRelation("users", "alice", "follows", "users", "bob"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPutRelationBoth(t *testing.T) {
+	edges := map[string]bool{}
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/users/fail/") {
+			w.WriteHeader(500)
+			fmt.Fprint(w, `{"message":"failed"}`)
+			return
+		}
+		switch r.Method {
+		case "GET":
+			if !edges[r.URL.Path] {
+				w.WriteHeader(404)
+				fmt.Fprint(w, `{"message":"not found"}`)
+				return
+			}
+			fmt.Fprint(w, `{}`)
+		case "PUT":
+			edges[r.URL.Path] = true
+			w.WriteHeader(204)
+		case "DELETE":
+			delete(edges, r.URL.Path)
+			w.WriteHeader(204)
+		}
+	}))
+
+	if err := c.PutRelationBoth("users", "alice", "friend", "users", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if !edges["/v0/users/alice/relation/friend/users/bob"] || !edges["/v0/users/bob/relation/friend/users/alice"] {
+		t.Errorf("expected both directions, got %v", edges)
+	}
+
+	if err := c.PutRelationBoth("users", "alice", "friend", "users", "fail"); err == nil {
+		t.Error("expected the failed second direction to be reported")
+	}
+	if edges["/v0/users/alice/relation/friend/users/fail"] {
+		t.Error("expected the first direction to be rolled back")
+	}
+
+	if err := c.DeleteRelationBoth("users", "alice", "friend", "users", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if len(edges) != 0 {
+		t.Errorf("expected both directions to be deleted, got %v", edges)
+	}
+}
+
+func TestPutRelationBothKeepsProperties(t *testing.T) {
+	edges := map[string]string{
+		"/v0/users/bob/relation/friend/users/alice": `{"since":2015}`,
+	}
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			properties, ok := edges[r.URL.Path]
+			if !ok {
+				w.WriteHeader(404)
+				fmt.Fprint(w, `{"message":"not found"}`)
+				return
+			}
+			fmt.Fprint(w, properties)
+		case "PUT":
+			var body []byte
+			if r.Body != nil {
+				body, _ = ioutil.ReadAll(r.Body)
+			}
+			edges[r.URL.Path] = string(body)
+			w.WriteHeader(204)
+		}
+	}))
+
+	if err := c.PutRelationBoth("users", "alice", "friend", "users", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := edges["/v0/users/alice/relation/friend/users/bob"]; !ok {
+		t.Error("expected the missing direction to be created")
+	}
+	if props := edges["/v0/users/bob/relation/friend/users/alice"]; props != `{"since":2015}` {
+		t.Errorf("expected the existing direction to keep its properties, got %q", props)
+	}
+}
+
+func TestDeleteRelationBothRollback(t *testing.T) {
+	edges := map[string]string{
+		"/v0/users/alice/relation/friend/users/fail": `{"since":2015}`,
+	}
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/users/fail/") {
+			w.WriteHeader(500)
+			fmt.Fprint(w, `{"message":"failed"}`)
+			return
+		}
+		switch r.Method {
+		case "GET":
+			fmt.Fprint(w, edges[r.URL.Path])
+		case "PUT":
+			body, _ := ioutil.ReadAll(r.Body)
+			edges[r.URL.Path] = string(body)
+			w.WriteHeader(204)
+		case "DELETE":
+			delete(edges, r.URL.Path)
+			w.WriteHeader(204)
+		}
+	}))
+
+	if err := c.DeleteRelationBoth("users", "alice", "friend", "users", "fail"); err == nil {
+		t.Error("expected the failed second direction to be reported")
+	}
+	if props := edges["/v0/users/alice/relation/friend/users/fail"]; props != `{"since":2015}` {
+		t.Errorf("expected the first direction to be restored with its properties, got %q", props)
+	}
+}
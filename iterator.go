@@ -0,0 +1,183 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+// A page of results that a pager can walk.
+type resultPage interface {
+	size() int
+	nextCursor() Cursor
+}
+
+func (r *KVResults) size() int          { return len(r.Results) }
+func (r *KVResults) nextCursor() Cursor { return r.Next }
+
+func (r *EventResults) size() int          { return len(r.Results) }
+func (r *EventResults) nextCursor() Cursor { return r.Next }
+
+func (r *GraphResults) size() int          { return len(r.Results) }
+func (r *GraphResults) nextCursor() Cursor { return r.Next }
+
+// Walks the results of a paged read one at a time, reading the first page
+// with first and each later one by following the next link of the page before
+// into a page made by empty. Each page is read into a new result set, so
+// results already returned remain valid. The iterators are built on it.
+type pager struct {
+	client *Client
+	first  func() (resultPage, error)
+	empty  func() resultPage
+
+	page  resultPage
+	index int
+	err   error
+}
+
+// Advances to the next result, as described by ListIterator.Next.
+func (p *pager) next() bool {
+	if p.err != nil {
+		return false
+	}
+
+	if p.page == nil {
+		if p.page, p.err = p.first(); p.err != nil {
+			return false
+		}
+		p.index = -1
+	}
+
+	for {
+		if p.index+1 < p.page.size() {
+			p.index++
+			return true
+		}
+		next := p.page.nextCursor()
+		if next == "" {
+			return false
+		}
+		page := p.empty()
+		if p.err = p.client.Follow(next, page); p.err != nil {
+			return false
+		}
+		p.page, p.index = page, -1
+	}
+}
+
+// Walks every value in a collection in key order, fetching a page at a time
+// and following the next link of each page as it is used up:
+//
+//	it := c.ListIterator("users", 100)
+//	for it.Next() {
+//	    result := it.Result()
+//	    // Use result
+//	}
+//	if err := it.Err(); err != nil {
+//	    return err
+//	}
+type ListIterator struct {
+	pager pager
+}
+
+// Returns an iterator over every value in a collection, read pageSize values
+// at a time. Nothing is read until the first call to Next.
+func (c *Client) ListIterator(collection string, pageSize int) *ListIterator {
+	return &ListIterator{pager{
+		client: c,
+		first: func() (resultPage, error) {
+			return c.List(collection, pageSize)
+		},
+		empty: func() resultPage { return new(KVResults) },
+	}}
+}
+
+// Advance to the next value, reading the next page if needed. Returns false
+// once every value has been seen or a page could not be read, after which Err
+// reports why.
+func (it *ListIterator) Next() bool {
+	return it.pager.next()
+}
+
+// Returns the current value. It remains valid after later calls to Next.
+func (it *ListIterator) Result() *KVResult {
+	return &it.pager.page.(*KVResults).Results[it.pager.index]
+}
+
+// Returns the error that stopped the iteration, or nil if it ran to the end
+// or is still running.
+func (it *ListIterator) Err() error {
+	return it.pager.err
+}
+
+// Walks every event of a particular type of a collection-key pair, newest
+// first, fetching a page at a time, in the same way as ListIterator.
+type EventIterator struct {
+	pager pager
+}
+
+// Returns an iterator over every event of a particular type of a
+// collection-key pair, read pageSize events at a time. Nothing is read until
+// the first call to Next.
+func (c *Client) EventIterator(collection, key, kind string, pageSize int) *EventIterator {
+	return &EventIterator{pager{
+		client: c,
+		first: func() (resultPage, error) {
+			return c.GetEventsPage(collection, key, kind, EventListOptions{Limit: pageSize})
+		},
+		empty: func() resultPage { return new(EventResults) },
+	}}
+}
+
+// Advance to the next event, reading the next page if needed. Returns false
+// once every event has been seen or a page could not be read, after which Err
+// reports why.
+func (it *EventIterator) Next() bool {
+	return it.pager.next()
+}
+
+// Returns the current event. It remains valid after later calls to Next.
+func (it *EventIterator) Event() *Event {
+	return &it.pager.page.(*EventResults).Results[it.pager.index]
+}
+
+// Returns the error that stopped the iteration, or nil if it ran to the end
+// or is still running.
+func (it *EventIterator) Err() error {
+	return it.pager.err
+}
+
+// Walks every key/value object related to a collection-key by a list of
+// relations, fetching a page at a time, in the same way as ListIterator.
+type RelationIterator struct {
+	pager pager
+}
+
+// Returns an iterator over every key/value object related to a collection-key
+// by a list of relations, read pageSize objects at a time, for walking all
+// the neighbors of a heavily connected node. Nothing is read until the first
+// call to Next.
+func (c *Client) RelationIterator(collection, key string, hops []string, pageSize int) *RelationIterator {
+	return &RelationIterator{pager{
+		client: c,
+		first: func() (resultPage, error) {
+			return c.GetRelationsPage(collection, key, hops, pageSize, 0)
+		},
+		empty: func() resultPage { return new(GraphResults) },
+	}}
+}
+
+// Advance to the next related object, reading the next page if needed.
+// Returns false once every object has been seen or a page could not be read,
+// after which Err reports why.
+func (it *RelationIterator) Next() bool {
+	return it.pager.next()
+}
+
+// Returns the current related object. It remains valid after later calls to
+// Next.
+func (it *RelationIterator) Result() *GraphResult {
+	return &it.pager.page.(*GraphResults).Results[it.pager.index]
+}
+
+// Returns the error that stopped the iteration, or nil if it ran to the end
+// or is still running.
+func (it *RelationIterator) Err() error {
+	return it.pager.err
+}
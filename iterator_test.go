@@ -0,0 +1,100 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestIterators(t *testing.T) {
+	type iterator interface {
+		Next() bool
+		Err() error
+	}
+
+	tests := []struct {
+		name string
+		// The response to each request, by its URI; any other request fails.
+		pages    map[string]string
+		iterate  func(c *Client) (iterator, func() string)
+		expected string
+		failed   bool
+	}{
+		{
+			name: "list",
+			pages: map[string]string{
+				"/v0/users?limit=2": `{"count":2,"results":[` +
+					`{"path":{"collection":"users","key":"a"},"value":{}},` +
+					`{"path":{"collection":"users","key":"b"},"value":{}}],` +
+					`"next":"/v0/users?limit=2&afterKey=b"}`,
+				"/v0/users?limit=2&afterKey=b": `{"count":0,"results":[],"next":"/v0/users?limit=2&afterKey=c"}`,
+				"/v0/users?limit=2&afterKey=c": `{"count":1,"results":[{"path":{"collection":"users","key":"d"},"value":{}}],` +
+					`"next":"/v0/users?limit=2&afterKey=d"}`,
+			},
+			iterate: func(c *Client) (iterator, func() string) {
+				it := c.ListIterator("users", 2)
+				return it, func() string { return it.Result().Path.Key }
+			},
+			expected: "[a b d]",
+			failed:   true,
+		},
+		{
+			name: "events",
+			pages: map[string]string{
+				"/v0/users/bob/events/login?limit=2": `{"count":2,"results":[{"timestamp":3,"ordinal":1},{"timestamp":2,"ordinal":1}],` +
+					`"next":"/v0/users/bob/events/login?limit=2&beforeEvent=2/1"}`,
+				"/v0/users/bob/events/login?limit=2&beforeEvent=2/1": `{"count":1,"results":[{"timestamp":1,"ordinal":1}]}`,
+			},
+			iterate: func(c *Client) (iterator, func() string) {
+				it := c.EventIterator("users", "bob", "login", 2)
+				return it, func() string { return it.Event().Kind + ":" + it.Event().Position() }
+			},
+			expected: "[login:3/1 login:2/1 login:1/1]",
+		},
+		{
+			name: "relations",
+			pages: map[string]string{
+				"/v0/users/alice/relations/follows/likes?limit=2&offset=0": `{"count":2,"results":[` +
+					`{"path":{"collection":"posts","key":"a"},"value":{}},` +
+					`{"path":{"collection":"posts","key":"b"},"value":{}}],` +
+					`"next":"/v0/users/alice/relations/follows/likes?limit=2&offset=2"}`,
+				"/v0/users/alice/relations/follows/likes?limit=2&offset=2": `{"count":1,"results":[{"path":{"collection":"posts","key":"c"},"value":{}}],` +
+					`"prev":"/v0/users/alice/relations/follows/likes?limit=2&offset=0"}`,
+			},
+			iterate: func(c *Client) (iterator, func() string) {
+				it := c.RelationIterator("users", "alice", []string{"follows", "likes"}, 2)
+				return it, func() string { return it.Result().Path.Key }
+			},
+			expected: "[a b c]",
+		},
+	}
+
+	for _, test := range tests {
+		c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+			page, ok := test.pages[r.URL.RequestURI()]
+			if !ok {
+				w.WriteHeader(500)
+				fmt.Fprint(w, `{"message":"failed"}`)
+				return
+			}
+			fmt.Fprint(w, page)
+		}))
+
+		it, item := test.iterate(c)
+		var items []string
+		for it.Next() {
+			items = append(items, item())
+		}
+		if fmt.Sprint(items) != test.expected {
+			t.Errorf("%s: expected %s, got %v", test.name, test.expected, items)
+		}
+		if failed := it.Err() != nil; failed != test.failed {
+			t.Errorf("%s: unexpected error %v", test.name, it.Err())
+		}
+		if it.Next() {
+			t.Errorf("%s: expected the iterator to stay stopped", test.name)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// Reports the kind of a raw JSON value from its first significant byte,
+// without decoding it: one of "object", "array", "string", "number",
+// "boolean" or "null". The rest of the value is not validated. This applies
+// to the RawValue of any result, including events, whose Kind field holds
+// the event type instead.
+func ValueKind(raw json.RawMessage) (string, error) {
+	trimmed := bytes.TrimLeft(raw, " \t\r\n")
+	if len(trimmed) == 0 {
+		return "", fmt.Errorf("empty JSON value")
+	}
+
+	switch b := trimmed[0]; {
+	case b == '{':
+		return "object", nil
+	case b == '[':
+		return "array", nil
+	case b == '"':
+		return "string", nil
+	case b == '-' || (b >= '0' && b <= '9'):
+		return "number", nil
+	case b == 't' || b == 'f':
+		return "boolean", nil
+	case b == 'n':
+		return "null", nil
+	default:
+		return "", fmt.Errorf("invalid JSON value starting with %q", b)
+	}
+}
+
+// Serializes a value as JSON with the keys of every object sorted and no
+// insignificant whitespace, so that equal values serialize identically and
+// can be compared byte for byte. Raw JSON, such as a RawValue, is decoded
+// first. Numbers keep their literal form, so 1 and 1.0 are not considered
+// equal, but large integers are never rounded.
+func CanonicalJSON(value interface{}) ([]byte, error) {
+	raw, ok := value.(json.RawMessage)
+	if !ok {
+		var err error
+		if raw, err = json.Marshal(value); err != nil {
+			return nil, err
+		}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+
+	var decoded interface{}
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(decoded)
+}
+
+// Reports whether two JSON values are equal, ignoring the order of object keys
+// and insignificant whitespace, as compared by CanonicalJSON. A value that is
+// not valid JSON is equal to nothing.
+func EqualValues(a, b json.RawMessage) bool {
+	ca, err := CanonicalJSON(a)
+	if err != nil {
+		return false
+	}
+
+	cb, err := CanonicalJSON(b)
+	if err != nil {
+		return false
+	}
+
+	return bytes.Equal(ca, cb)
+}
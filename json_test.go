@@ -0,0 +1,68 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestValueKind(t *testing.T) {
+	tests := map[string]string{
+		`{"a":1}`:  "object",
+		` [1, 2]`:  "array",
+		`"text"`:   "string",
+		`-1.5e3`:   "number",
+		`42`:       "number",
+		`true`:     "boolean",
+		`false`:    "boolean",
+		"\n\tnull": "null",
+	}
+
+	for raw, expected := range tests {
+		if kind, err := ValueKind([]byte(raw)); err != nil || kind != expected {
+			t.Errorf("ValueKind(%q) = %q, %v; expected %q", raw, kind, err, expected)
+		}
+	}
+
+	for _, raw := range []string{"", "   ", "<xml/>"} {
+		if _, err := ValueKind([]byte(raw)); err == nil {
+			t.Errorf("ValueKind(%q): expected an error", raw)
+		}
+	}
+}
+
+func TestCanonicalJSON(t *testing.T) {
+	a, err := CanonicalJSON(json.RawMessage(`{ "b": {"y": 1, "x": 2}, "a": [true, null] }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := CanonicalJSON(map[string]interface{}{"a": []interface{}{true, nil}, "b": map[string]int{"x": 2, "y": 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(a) != `{"a":[true,null],"b":{"x":2,"y":1}}` || string(a) != string(b) {
+		t.Errorf("expected equal canonical forms, got %s and %s", a, b)
+	}
+}
+
+func TestEqualValues(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		equal bool
+	}{
+		{`{"a":1,"b":2}`, `{"b":2,"a":1}`, true},
+		{"{\n  \"a\": [1, 2],\n  \"b\": {\"d\": null, \"c\": \"x\"}\n}", `{"b":{"c":"x","d":null},"a":[1,2]}`, true},
+		{`[1,2]`, `[2,1]`, false},
+		{`{"a":1}`, `{"a":1,"b":null}`, false},
+		{`{"n":1152921504606846977}`, `{"n":1152921504606846976}`, false},
+		{`{"a":`, `{"a":`, false},
+	}
+
+	for _, test := range tests {
+		if equal := EqualValues([]byte(test.a), []byte(test.b)); equal != test.equal {
+			t.Errorf("EqualValues(%s, %s) = %v, expected %v", test.a, test.b, equal, test.equal)
+		}
+	}
+}
@@ -5,23 +5,34 @@ package gorc
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
+// Returned by DeleteIfValue when the value held is not the one expected.
+var ErrValueChanged = errors.New("value changed")
+
 // Holds results returned from a KV list query.
 type KVResults struct {
 	Count   uint64     `json:"count"`
 	Results []KVResult `json:"results"`
-	Next    string     `json:"next,omitempty"`
+	Next    Cursor     `json:"next,omitempty"`
 }
 
 // An individual Key/Value result.
 type KVResult struct {
 	Path     Path            `json:"path"`
 	RawValue json.RawMessage `json:"value"`
+
+	// The Content-Type the value was returned with, when read on its own
+	// with Get or GetPath. Results listed with others leave this empty.
+	ContentType string `json:"-"`
 }
 
 // Get a collection-key pair's value.
@@ -31,38 +42,232 @@ func (c *Client) Get(collection, key string) (*KVResult, error) {
 
 // Get the value at a path.
 func (c *Client) GetPath(path *Path) (*KVResult, error) {
-	resp, err := c.doRequest("GET", path.trailingGetURI(), nil, nil)
+	if c.flights != nil && path.Ref == "" && len(c.extraHeaders) == 0 && c.authenticator == nil {
+		key := flightKey{authToken: c.authToken, path: *path}
+		return c.flights.do(c.baseContext(), key, func() (*KVResult, error) {
+			shared := *path
+			return c.getPath(&shared)
+		})
+	}
+
+	return c.getPath(path)
+}
+
+// Get the value at a path, from the cache if it is enabled.
+func (c *Client) getPath(path *Path) (*KVResult, error) {
+	if c.cache != nil && path.Ref == "" {
+		return c.getCached(path)
+	}
+
+	result, _, err := c.doGet(path, nil)
+	return result, err
+}
+
+// Returned by MultiGet and MultiGetPaths when some of the values could not be
+// read. Errors holds the error for each path at the index it was given in,
+// nil for those that were read; a missing key's error matches ErrNotFound.
+type MultiGetError struct {
+	Errors []error
+}
+
+func (e *MultiGetError) Error() string {
+	failed, first := 0, error(nil)
+	for _, err := range e.Errors {
+		if err != nil {
+			if first == nil {
+				first = err
+			}
+			failed++
+		}
+	}
+	return fmt.Sprintf("%d of %d values could not be read, the first: %v", failed, len(e.Errors), first)
+}
+
+// Get the values of many keys of a collection, making at most concurrency
+// requests at once. See MultiGetPaths.
+func (c *Client) MultiGet(collection string, keys []string, concurrency int) ([]*KVResult, error) {
+	paths := make([]*Path, len(keys))
+	for i, key := range keys {
+		paths[i] = &Path{Collection: collection, Key: key}
+	}
+
+	return c.MultiGetPaths(paths, concurrency)
+}
+
+// Get the values at many paths with GetPath, making at most concurrency
+// requests at once. The results are returned in the order of the paths. If
+// any value could not be read, including because its key holds none, the
+// others are still returned, with nil in place of the missing ones, along
+// with a *MultiGetError reporting why each failed.
+func (c *Client) MultiGetPaths(paths []*Path, concurrency int) ([]*KVResult, error) {
+	results := make([]*KVResult, len(paths))
+	errs := make([]error, len(paths))
+	parallel(len(paths), concurrency, func(i int) {
+		path := *paths[i]
+		results[i], errs[i] = c.GetPath(&path)
+	})
+
+	for _, err := range errs {
+		if err != nil {
+			return results, &MultiGetError{Errors: errs}
+		}
+	}
+	return results, nil
+}
+
+// Get the value a collection-key pair held at the given ref, or its current
+// value if that ref is no longer available, for example because the key's
+// history was purged. The returned bool reports whether the value is the one
+// at the given ref. If the key holds no value either, the returned error
+// matches ErrNotFound.
+func (c *Client) GetRefOrLatest(collection, key, ref string) (*KVResult, bool, error) {
+	result, err := c.GetPath(NewPath(collection, key).WithRef(ref))
+	if err == nil {
+		return result, true, nil
+	} else if !errors.Is(err, ErrNotFound) {
+		return nil, false, err
+	}
+
+	result, err = c.Get(collection, key)
 	if err != nil {
-		return nil, err
+		return nil, false, err
+	}
+	return result, result.Path.Ref == normalizeRef(ref), nil
+}
+
+// Get the current value of the collection-key pair at the path, unless it
+// still holds the value at the path's ref. The returned bool reports whether
+// the value has changed; when it has not, the result is nil and nothing but
+// the status is transferred, which lets an application keep its own copies of
+// values up to date cheaply. A path without a ref is always read.
+func (c *Client) GetIfModified(path *Path) (*KVResult, bool, error) {
+	current := &Path{Collection: path.Collection, Key: path.Key}
+	if path.Ref == "" {
+		result, err := c.GetPath(current)
+		return result, err == nil, err
+	}
+
+	headers := map[string]string{
+		"If-None-Match": quoteRef(path.Ref),
+	}
+
+	return c.doGet(current, headers)
+}
+
+// Get a collection-key pair's value as a stream, along with its path. The
+// response body is returned as it arrives rather than read into memory, so a
+// large value can be copied straight to a file. The caller must close the
+// returned reader. Values read this way bypass the cache.
+func (c *Client) GetStream(collection, key string) (io.ReadCloser, *Path, error) {
+	resp, err := c.doRequest("GET", buildURI(collection, key), nil, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if resp.StatusCode != 200 {
+		defer resp.Body.Close()
+		return nil, nil, newError(resp)
+	}
+
+	ref, err := refFromHeader(resp.Header, c.baseURL)
+	if err != nil {
+		resp.Body.Close()
+		return nil, nil, err
+	}
+
+	return resp.Body, &Path{Collection: collection, Key: key, Ref: ref}, nil
+}
+
+// Execute a key/value get. When the headers make the request conditional and
+// the server reports the value has not been modified, the result is nil and
+// the returned bool false.
+func (c *Client) doGet(path *Path, headers map[string]string) (*KVResult, bool, error) {
+	resp, err := c.doRequest("GET", path.trailingGetURI(), headers, nil)
+	if err != nil {
+		return nil, false, err
 	}
 
 	defer resp.Body.Close()
 
+	if resp.StatusCode == 304 {
+		return nil, false, nil
+	}
+
 	if resp.StatusCode != 200 {
-		return nil, newError(resp)
+		return nil, false, newError(resp)
 	}
 
 	// TODO: Check for a content-length header so we can pre-allocate buffer
 	// space.
 	buf := bytes.NewBuffer(nil)
 	if _, err := buf.ReadFrom(resp.Body); err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
 	if path.Ref == "" {
-		path.Ref = strings.SplitAfter(resp.Header.Get("Content-Location"), "/")[5]
+		if path.Ref, err = refFromHeader(resp.Header, c.baseURL); err != nil {
+			return nil, false, err
+		}
+	}
+
+	value, err := c.transformInbound(buf.Bytes())
+	if err != nil {
+		return nil, false, err
+	}
+
+	result := &KVResult{
+		Path:        *path,
+		RawValue:    value,
+		ContentType: resp.Header.Get("Content-Type"),
+	}
+	return result, true, nil
+}
+
+// Check whether a collection-key pair currently holds a value, without
+// transferring the value itself.
+func (c *Client) Exists(collection, key string) (bool, error) {
+	resp, err := c.doRequest("HEAD", buildURI(collection, key), nil, nil)
+	if err != nil {
+		return false, err
+	}
+
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case 200:
+		return true, nil
+	case 404:
+		return false, nil
+	}
+
+	return false, newError(resp)
+}
+
+// Get the current ref of a collection-key pair, without transferring its
+// value. If the key holds no value the returned error matches ErrNotFound.
+func (c *Client) GetRefOnly(collection, key string) (string, error) {
+	resp, err := c.doRequest("HEAD", buildURI(collection, key), nil, nil)
+	if err != nil {
+		return "", err
 	}
 
-	return &KVResult{Path: *path, RawValue: buf.Bytes()}, nil
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return "", newError(resp)
+	}
+
+	return refFromHeader(resp.Header, c.baseURL)
 }
 
 // Store a value to a collection-key pair.
 func (c *Client) Put(collection string, key string, value interface{}) (*Path, error) {
-	reader, writer := io.Pipe()
-	encoder := json.NewEncoder(writer)
+	buf, err := encodeJSON(value)
+	if err != nil {
+		return nil, err
+	}
 
-	go func() { writer.CloseWithError(encoder.Encode(value)) }()
-	return c.PutRaw(collection, key, reader)
+	return c.PutRaw(collection, key, buf)
 }
 
 // Store a value to a collection-key pair.
@@ -70,19 +275,59 @@ func (c *Client) PutRaw(collection string, key string, value io.Reader) (*Path,
 	return c.doPut(&Path{Collection: collection, Key: key}, nil, value)
 }
 
+// Store a value to a collection-key pair, encoding it with a json.Encoder
+// that configure can adjust first, for example to disable HTML escaping with
+// SetEscapeHTML(false) so that "<", ">" and "&" are stored as is.
+func (c *Client) PutWithEncoder(collection, key string, value interface{}, configure func(*json.Encoder)) (*Path, error) {
+	buf, err := encodeJSONWith(value, configure)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.PutRaw(collection, key, buf)
+}
+
+// Store a JSON document received from elsewhere to a collection-key pair,
+// writing its numbers exactly as they appear. Decoding such a document into
+// an interface{} before calling Put turns its numbers into float64, which can
+// not hold integers beyond 2^53 exactly. The document is checked to be valid
+// JSON and compacted, but is otherwise stored as given. Read it back with
+// ValuePreserveNumbers to keep the numbers exact through a round trip.
+func (c *Client) PutPreserveNumbers(collection, key string, data []byte) (*Path, error) {
+	buf := new(bytes.Buffer)
+	if err := json.Compact(buf, data); err != nil {
+		return nil, err
+	}
+
+	return c.PutRaw(collection, key, buf)
+}
+
+// Store the JSON written by encode to a collection-key pair. The output is
+// streamed into the request body as it is produced, so very large values are
+// never held in memory in their entirety. An error returned from encode
+// aborts the request.
+func (c *Client) PutStream(collection, key string, encode func(io.Writer) error) (*Path, error) {
+	reader, writer := io.Pipe()
+	defer reader.Close()
+
+	go func() { writer.CloseWithError(encode(writer)) }()
+	return c.PutRaw(collection, key, reader)
+}
+
 // Store a value to a collection-key pair if the path's ref value is the latest.
 func (c *Client) PutIfUnmodified(path *Path, value interface{}) (*Path, error) {
-	reader, writer := io.Pipe()
-	encoder := json.NewEncoder(writer)
+	buf, err := encodeJSON(value)
+	if err != nil {
+		return nil, err
+	}
 
-	go func() { writer.CloseWithError(encoder.Encode(value)) }()
-	return c.PutIfUnmodifiedRaw(path, reader)
+	return c.PutIfUnmodifiedRaw(path, buf)
 }
 
 // Store a value to a collection-key pair if the path's ref value is the latest.
 func (c *Client) PutIfUnmodifiedRaw(path *Path, value io.Reader) (*Path, error) {
 	headers := map[string]string{
-		"If-Match": `"` + path.Ref + `"`,
+		"If-Match": quoteRef(path.Ref),
 	}
 
 	return c.doPut(path, headers, value)
@@ -90,11 +335,12 @@ func (c *Client) PutIfUnmodifiedRaw(path *Path, value io.Reader) (*Path, error)
 
 // Store a value to a collection-key pair if it doesn't already hold a value.
 func (c *Client) PutIfAbsent(collection, key string, value interface{}) (*Path, error) {
-	reader, writer := io.Pipe()
-	encoder := json.NewEncoder(writer)
+	buf, err := encodeJSON(value)
+	if err != nil {
+		return nil, err
+	}
 
-	go func() { writer.CloseWithError(encoder.Encode(value)) }()
-	return c.PutIfAbsentRaw(collection, key, reader)
+	return c.PutIfAbsentRaw(collection, key, buf)
 }
 
 // Store a value to a collection-key pair if it doesn't already hold a value.
@@ -106,9 +352,57 @@ func (c *Client) PutIfAbsentRaw(collection, key string, value io.Reader) (*Path,
 	return c.doPut(&Path{Collection: collection, Key: key}, headers, value)
 }
 
+// Replace the value of a collection-key pair with the one mutate makes from
+// it, guarding against concurrent writers. The current value is read and
+// passed to mutate, or nil if the key holds no value, and the result is
+// written only if the key has not changed in the meantime. If another writer
+// got there first, the read and mutate are repeated, up to maxRetries more
+// times, after which the returned error matches ErrPreconditionFailed. An
+// error from mutate is returned as is. Since mutate may be called several
+// times it should not have side effects.
+func (c *Client) Update(collection, key string, mutate func(current json.RawMessage) (interface{}, error), maxRetries int) (*Path, error) {
+	for attempt := 0; ; attempt++ {
+		var current json.RawMessage
+		var path *Path
+
+		result, err := c.Get(collection, key)
+		if err == nil {
+			current, path = result.RawValue, &result.Path
+		} else if !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+
+		value, err := mutate(current)
+		if err != nil {
+			return nil, err
+		}
+
+		var written *Path
+		if path == nil {
+			written, err = c.PutIfAbsent(collection, key, value)
+		} else {
+			written, err = c.PutIfUnmodified(path, value)
+		}
+
+		if err == nil || !errors.Is(err, ErrPreconditionFailed) || attempt >= maxRetries {
+			return written, err
+		}
+	}
+}
+
 // Execute a key/value Put.
 func (c *Client) doPut(path *Path, headers map[string]string, value io.Reader) (*Path, error) {
-	resp, err := c.doRequest("PUT", path.trailingPutURI(), headers, value)
+	value, err := c.transformOutbound(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.doWrite("PUT", path, headers, value)
+}
+
+// Execute a key/value write that responds with the location of the new ref.
+func (c *Client) doWrite(method string, path *Path, headers map[string]string, value io.Reader) (*Path, error) {
+	resp, err := c.doRequest(method, path.trailingPutURI(), headers, value)
 	if err != nil {
 		return nil, err
 	}
@@ -119,38 +413,162 @@ func (c *Client) doPut(path *Path, headers map[string]string, value io.Reader) (
 		return nil, newError(resp)
 	}
 
-	ref := strings.SplitAfter(resp.Header.Get("Location"), "/")[5]
+	ref, err := parseRefLocation(resp.Header.Get("Location"), c.baseURL)
+	if err != nil {
+		return nil, err
+	}
 
 	return &Path{
 		Collection: path.Collection,
 		Key:        path.Key,
 		Ref:        ref,
-	}, err
+	}, nil
 }
 
-// Delete the value held at a collection-key pair.
+// Copy the value held at a collection-key pair to another collection-key
+// pair, returning the path of the new value. If the source holds no value the
+// returned error matches ErrNotFound.
+func (c *Client) Copy(srcCollection, srcKey, dstCollection, dstKey string) (*Path, error) {
+	return c.CopyPath(&Path{Collection: srcCollection, Key: srcKey}, dstCollection, dstKey)
+}
+
+// Copy the value at a path, which may name a specific ref, to a
+// collection-key pair, returning the path of the new value.
+func (c *Client) CopyPath(src *Path, dstCollection, dstKey string) (*Path, error) {
+	result, err := c.GetPath(src)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.PutRaw(dstCollection, dstKey, bytes.NewReader(result.RawValue))
+}
+
+// Delete the value held at a collection-key pair. The key's history of
+// values is preserved, with the delete recorded as a tombstone, so the value
+// can be recovered with Restore. Use Purge to delete the history as well.
 func (c *Client) Delete(collection, key string) error {
-	return c.doDelete(collection+"/"+key, nil)
+	return c.doDelete(buildURI(collection, key), nil)
+}
+
+// Delete the value held at a collection-key pair, preserving its history.
+// This is the same as Delete, named to make the intent explicit alongside
+// Purge, which destroys the history.
+func (c *Client) SoftDelete(collection, key string) error {
+	return c.Delete(collection, key)
+}
+
+// Store the most recent value a collection-key pair held before it was
+// deleted, returning the path of the restored value. If the key holds a value
+// already, nothing is written and the current path is returned. If none of the
+// key's history holds a value, for example because it was purged, the
+// returned error matches ErrNotFound.
+func (c *Client) Restore(collection, key string) (*Path, error) {
+	results, err := c.ListRefs(collection, key, RefListOptions{Limit: 10, Values: true})
+	if err != nil {
+		return nil, err
+	}
+
+	// The history is listed newest first, so a live value at the head means
+	// the key has not been deleted.
+	if len(results.Results) > 0 && !results.Results[0].Path.Tombstone {
+		path := results.Results[0].Path
+		return &path, nil
+	}
+
+	for {
+		for _, result := range results.Results {
+			if !result.Path.Tombstone {
+				return c.PutRaw(collection, key, bytes.NewReader(result.RawValue))
+			}
+		}
+
+		if !results.HasNext() {
+			return nil, ErrNotFound
+		}
+		if err := c.Follow(results.Next, results); err != nil {
+			return nil, err
+		}
+	}
 }
 
 // Delete the value held at a collection-key par if the path's ref value is the
 // latest.
 func (c *Client) DeleteIfUnmodified(path *Path) error {
 	headers := map[string]string{
-		"If-Match": `"` + path.Ref + `"`,
+		"If-Match": quoteRef(path.Ref),
 	}
 
 	return c.doDelete(path.trailingPutURI(), headers)
 }
 
+// Delete the value held at a collection-key pair if it is equal to expected,
+// comparing their JSON with object keys in any order. The delete is made
+// conditional on the ref that was compared, so if the value differs, or is
+// replaced before it can be deleted, nothing is deleted and ErrValueChanged
+// is returned. If the key holds no value the returned error matches
+// ErrNotFound.
+func (c *Client) DeleteIfValue(collection, key string, expected interface{}) error {
+	want, err := CanonicalJSON(expected)
+	if err != nil {
+		return err
+	}
+
+	current, err := c.Get(collection, key)
+	if err != nil {
+		return err
+	}
+
+	have, err := CanonicalJSON(current.RawValue)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(have, want) {
+		return ErrValueChanged
+	}
+
+	err = c.DeleteIfUnmodified(&current.Path)
+	if errors.Is(err, ErrPreconditionFailed) {
+		return ErrValueChanged
+	}
+	return err
+}
+
 // Delete the current and all previous values from a collection-key pair.
 func (c *Client) Purge(collection, key string) error {
-	return c.doDelete(collection+"/"+key+"?purge=true", nil)
+	return c.doDelete(buildURI(collection, key)+"?purge=true", nil)
+}
+
+// The key EnsureCollection writes to force a collection into existence.
+const sentinelKey = ".gorc-ensure-collection"
+
+// Make sure a collection exists, creating it if need be. Orchestrate creates
+// collections implicitly on their first write and has no endpoint to create
+// one, so if listing the collection finds no keys, a sentinel value is
+// written to the key ".gorc-ensure-collection" and then purged, leaving the
+// collection empty but present. The sentinel is only written if that key is
+// absent, so an existing value there is never replaced. This is safe to call
+// repeatedly.
+func (c *Client) EnsureCollection(collection string) error {
+	results, err := c.List(collection, 1)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	} else if err == nil && len(results.Results) > 0 {
+		return nil
+	}
+
+	_, err = c.PutIfAbsent(collection, sentinelKey, struct{}{})
+	if errors.Is(err, ErrPreconditionFailed) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	return c.Purge(collection, sentinelKey)
 }
 
 // Delete a collection.
 func (c *Client) DeleteCollection(collection string) error {
-	return c.doDelete(collection+"?force=true", nil)
+	return c.doDelete(buildURI(collection)+"?force=true", nil)
 }
 
 // Execute delete
@@ -176,7 +594,7 @@ func (c *Client) List(collection string, limit int) (*KVResults, error) {
 		"limit": []string{strconv.Itoa(limit)},
 	}
 
-	trailingUri := collection + "?" + queryVariables.Encode()
+	trailingUri := buildURI(collection) + "?" + queryVariables.Encode()
 
 	return c.doList(trailingUri)
 }
@@ -189,7 +607,7 @@ func (c *Client) ListAfter(collection, after string, limit int) (*KVResults, err
 		"afterKey": []string{after},
 	}
 
-	trailingUri := collection + "?" + queryVariables.Encode()
+	trailingUri := buildURI(collection) + "?" + queryVariables.Encode()
 
 	return c.doList(trailingUri)
 }
@@ -202,36 +620,243 @@ func (c *Client) ListStart(collection, start string, limit int) (*KVResults, err
 		"startKey": []string{start},
 	}
 
-	trailingUri := collection + "?" + queryVariables.Encode()
+	trailingUri := buildURI(collection) + "?" + queryVariables.Encode()
+
+	return c.doList(trailingUri)
+}
+
+// List the values in a collection whose keys begin with the specified prefix,
+// in key order with the specified page size. The range is bounded on the
+// server, so subsequent pages can be fetched with ListGetNext.
+func (c *Client) ListPrefix(collection, prefix string, limit int) (*KVResults, error) {
+	return c.ListRange(collection, ListRangeOptions{
+		Limit:     limit,
+		StartKey:  prefix,
+		BeforeKey: prefixSuccessor(prefix),
+	})
+}
+
+// Returns the smallest key that sorts after every key beginning with prefix,
+// or "" if there is none. Keys sort by their UTF-8 bytes, which is the order
+// of their code points, so this is the prefix with its last character
+// replaced by the next one, dropping any trailing characters that have none.
+func prefixSuccessor(prefix string) string {
+	for prefix != "" {
+		r, size := utf8.DecodeLastRuneInString(prefix)
+		prefix = prefix[:len(prefix)-size]
+
+		switch {
+		case r == utf8.MaxRune || r == utf8.RuneError:
+			continue
+		case r == 0xd7ff:
+			// Skip the surrogates, which are not valid in UTF-8.
+			r = 0xe000
+		default:
+			r++
+		}
+		return prefix + string(r)
+	}
+	return ""
+}
+
+// The bounds of a range of keys listed by ListRange. At most one lower and
+// one upper bound may be given; an empty bound leaves that end open.
+type ListRangeOptions struct {
+	// The size of each page.
+	Limit int
+
+	// List keys from StartKey, inclusive, or after AfterKey, exclusive.
+	StartKey string
+	AfterKey string
+
+	// List keys up to EndKey, inclusive, or before BeforeKey, exclusive.
+	EndKey    string
+	BeforeKey string
+}
+
+// List the values in a collection whose keys lie in a range, in key order.
+// The range is bounded on the server, so subsequent pages can be fetched with
+// ListGetNext.
+func (c *Client) ListRange(collection string, opts ListRangeOptions) (*KVResults, error) {
+	if opts.StartKey != "" && opts.AfterKey != "" {
+		return nil, errors.New("only one of StartKey and AfterKey may be given")
+	}
+	if opts.EndKey != "" && opts.BeforeKey != "" {
+		return nil, errors.New("only one of EndKey and BeforeKey may be given")
+	}
+
+	queryVariables := url.Values{
+		"limit": []string{strconv.Itoa(opts.Limit)},
+	}
+	bounds := map[string]string{
+		"startKey":  opts.StartKey,
+		"afterKey":  opts.AfterKey,
+		"endKey":    opts.EndKey,
+		"beforeKey": opts.BeforeKey,
+	}
+	for name, key := range bounds {
+		if key != "" {
+			queryVariables.Set(name, key)
+		}
+	}
+
+	trailingUri := buildURI(collection) + "?" + queryVariables.Encode()
 
 	return c.doList(trailingUri)
 }
 
 // Get the page of key/value list results that follow that provided set.
 func (c *Client) ListGetNext(results *KVResults) (*KVResults, error) {
-	return c.doList(results.Next[4:])
+	next := new(KVResults)
+	if err := c.Follow(results.Next, next); err != nil {
+		return nil, err
+	}
+	return next, nil
+}
+
+// Like List, except that the page is decoded into the provided results rather
+// than a newly allocated set, reusing the capacity of its Results slice. This
+// reduces allocation when scanning through many pages, for example with
+// Follow. Any results previously held are overwritten, so callers must not
+// retain references to them, or to their RawValue, across calls. If an error
+// is returned the contents of results are unspecified.
+func (c *Client) ListInto(collection string, limit int, results *KVResults) error {
+	queryVariables := url.Values{
+		"limit": []string{strconv.Itoa(limit)},
+	}
+
+	trailingUri := buildURI(collection) + "?" + queryVariables.Encode()
+
+	return c.doListInto(trailingUri, results)
+}
+
+// Selects which entries of a key's history ListRefs returns.
+type TombstoneFilter int
+
+const (
+	// Return every ref, live values and tombstones alike.
+	AllRefs TombstoneFilter = iota
+
+	// Return only refs that hold a value.
+	LiveRefs
+
+	// Return only the tombstones left by deletes.
+	TombstoneRefs
+)
+
+// Options for listing the history of a collection-key pair with ListRefs.
+type RefListOptions struct {
+	// The maximum number of refs to fetch, and the number of refs to skip
+	// from the newest. A zero Limit uses the server's default.
+	Limit  int
+	Offset int
+
+	// Fetch the value stored at each ref along with its path.
+	Values bool
+
+	// Which refs to return. Orchestrate lists tombstones alongside values,
+	// so the filter is applied by the client to each page; a filtered page
+	// may hold fewer than Limit refs while still having a next page.
+	Tombstones TombstoneFilter
+}
+
+// Get the history of a collection-key pair, newest first. Each result's
+// Path.Tombstone reports whether its ref marks a delete rather than a value.
+func (c *Client) ListRefs(collection, key string, opts RefListOptions) (*KVResults, error) {
+	queryVariables := url.Values{}
+	if opts.Limit > 0 {
+		queryVariables.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if opts.Offset > 0 {
+		queryVariables.Set("offset", strconv.Itoa(opts.Offset))
+	}
+	if opts.Values {
+		queryVariables.Set("values", "true")
+	}
+
+	trailingUri := buildURI(collection, key, "refs") + "/?" + queryVariables.Encode()
+
+	results, err := c.doList(trailingUri)
+	if err != nil {
+		return nil, err
+	}
+
+	results.filterRefs(opts.Tombstones)
+	return results, nil
+}
+
+// Get the page of refs that follow that provided set, applying the same
+// filter that ListRefs did.
+func (c *Client) ListRefsGetNext(results *KVResults, opts RefListOptions) (*KVResults, error) {
+	next, err := c.ListGetNext(results)
+	if err != nil {
+		return nil, err
+	}
+
+	next.filterRefs(opts.Tombstones)
+	return next, nil
+}
+
+// Drops the results that the filter excludes, in place.
+func (r *KVResults) filterRefs(filter TombstoneFilter) {
+	if filter == AllRefs {
+		return
+	}
+
+	kept := r.Results[:0]
+	for _, result := range r.Results {
+		if result.Path.Tombstone == (filter == TombstoneRefs) {
+			kept = append(kept, result)
+		}
+	}
+	r.Results = kept
+	r.Count = uint64(len(kept))
 }
 
 // Execute a key/value list operation.
 func (c *Client) doList(trailingUri string) (*KVResults, error) {
+	result := new(KVResults)
+	if err := c.doListInto(trailingUri, result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Execute a key/value list operation, decoding into the provided results.
+func (c *Client) doListInto(trailingUri string, results *KVResults) error {
 	resp, err := c.doRequest("GET", trailingUri, nil, nil)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		return nil, newError(resp)
+		return newError(resp)
 	}
 
-	decoder := json.NewDecoder(resp.Body)
-	result := new(KVResults)
-	if err := decoder.Decode(result); err != nil {
-		return result, err
+	// Clear out the previous page so that fields absent from this one can
+	// not leak through, keeping only the slice's capacity.
+	reused := results.Results[:cap(results.Results)]
+	for i := range reused {
+		reused[i] = KVResult{}
 	}
+	*results = KVResults{Results: reused[:0]}
 
-	return result, nil
+	if err := decodeResponse(resp, results, "list results"); err != nil {
+		return err
+	}
+
+	for i := range results.Results {
+		value, err := c.transformInbound(results.Results[i].RawValue)
+		if err != nil {
+			return err
+		}
+		results.Results[i].RawValue = value
+	}
+
+	return nil
 }
 
 // Check if there is a subsequent page of key/value list results.
@@ -239,20 +864,143 @@ func (r *KVResults) HasNext() bool {
 	return r.Next != ""
 }
 
+// Call fn with each result of the current page in turn, until it asks to stop
+// or returns an error, which is then returned. Values are left undecoded so
+// that fn can decode only those it needs with Value.
+func (r *KVResults) Each(fn func(*KVResult) (stop bool, err error)) error {
+	for i := range r.Results {
+		if stop, err := fn(&r.Results[i]); err != nil || stop {
+			return err
+		}
+	}
+	return nil
+}
+
 // Marshall the value of a KVResult into the provided object.
 func (r *KVResult) Value(value interface{}) error {
 	return json.Unmarshal(r.RawValue, value)
 }
 
+// Marshall the value of a KVResult into the provided object as Value does,
+// except that numbers decoded into an interface{} become json.Number rather
+// than float64, so that large integers survive exactly and are written back
+// unchanged by Put.
+func (r *KVResult) ValuePreserveNumbers(value interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(r.RawValue))
+	decoder.UseNumber()
+	return decoder.Decode(value)
+}
+
+// Returns a copy of the raw JSON value of a KVResult.
+func (r *KVResult) Raw() []byte {
+	return append([]byte(nil), r.RawValue...)
+}
+
+// Returns the size in bytes of the raw JSON value of a KVResult.
+func (r *KVResult) Size() int {
+	return len(r.RawValue)
+}
+
+// Reports the top level JSON kind of the value of a KVResult, as ValueKind does.
+func (r *KVResult) Kind() (string, error) {
+	return ValueKind(r.RawValue)
+}
+
+// Returns a new Path for a collection-key pair, without a ref.
+func NewPath(collection, key string) *Path {
+	return &Path{Collection: collection, Key: key}
+}
+
+// Returns a copy of the path with the given ref, for use with conditional
+// operations such as PutIfUnmodified. Quoting and weak validator prefixes, as
+// found in an ETag header, are stripped from the ref.
+func (p Path) WithRef(ref string) *Path {
+	p.Ref = normalizeRef(ref)
+	return &p
+}
+
+// Parses a path from its composite form, "collection/key" or
+// "collection/key/ref", as returned by Path.String. Each part is unescaped, so
+// a slash within a key is written "%2F".
+func ParsePath(s string) (*Path, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 2 && len(parts) != 3 {
+		return nil, fmt.Errorf("invalid path %q", s)
+	}
+
+	for i, part := range parts {
+		unescaped, err := url.PathUnescape(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path %q: %v", s, err)
+		}
+		if unescaped == "" {
+			return nil, fmt.Errorf("invalid path %q", s)
+		}
+		parts[i] = unescaped
+	}
+
+	path := &Path{Collection: parts[0], Key: parts[1]}
+	if len(parts) == 3 {
+		path.Ref = parts[2]
+	}
+	return path, nil
+}
+
+// Returns the composite form of the path, "collection/key" or, if it has a
+// ref, "collection/key/ref", with each part escaped. ParsePath reverses this.
+func (p *Path) String() string {
+	if p.Ref != "" {
+		return buildURI(p.Collection, p.Key, p.Ref)
+	}
+	return buildURI(p.Collection, p.Key)
+}
+
 // Returns the trailing URI part for a GET request.
 func (p *Path) trailingGetURI() string {
 	if p.Ref != "" {
-		return p.Collection + "/" + p.Key + "/refs/" + p.Ref
+		return buildURI(p.Collection, p.Key, "refs", p.Ref)
 	}
-	return p.Collection + "/" + p.Key
+	return buildURI(p.Collection, p.Key)
 }
 
 // Returns the trailing URI part for a PUT request.
 func (p *Path) trailingPutURI() string {
-	return p.Collection + "/" + p.Key
+	return buildURI(p.Collection, p.Key)
+}
+
+// Extracts the ref of a value from the headers of a response to a GET or
+// HEAD, taken from the ETag or, failing that, the Content-Location, which is
+// resolved against roots as locationSegments does.
+func refFromHeader(header http.Header, roots ...string) (string, error) {
+	if etag := header.Get("ETag"); etag != "" {
+		return normalizeRef(etag), nil
+	}
+	return parseRefLocation(header.Get("Content-Location"), roots...)
+}
+
+// Extracts the ref from a location of the form /v0/collection/key/refs/ref,
+// resolved against roots as locationSegments does.
+func parseRefLocation(location string, roots ...string) (string, error) {
+	segments, err := locationSegments(location, roots...)
+	if err != nil {
+		return "", err
+	}
+
+	if len(segments) != 4 || segments[2] != "refs" || segments[3] == "" {
+		return "", fmt.Errorf("unexpected ref location %q", location)
+	}
+
+	return segments[3], nil
+}
+
+// Strips any quoting or weak validator prefix from a ref, as found in an
+// ETag header for example, leaving the bare ref.
+func normalizeRef(ref string) string {
+	ref = strings.TrimPrefix(strings.TrimSpace(ref), "W/")
+	return strings.Trim(ref, `"`)
+}
+
+// Quotes a ref for use in an If-Match or If-None-Match header.
+func quoteRef(ref string) string {
+	return `"` + normalizeRef(ref) + `"`
 }
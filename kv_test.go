@@ -3,12 +3,23 @@
 package gorc
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
 	"testing"
 	"testing/quick"
 )
 
 func TestKVHasNext(t *testing.T) {
 	f := func(results *KVResults) bool {
+		if results == nil {
+			return true
+		}
 		return !(results.Next == "" && results.HasNext())
 	}
 
@@ -19,10 +30,14 @@ func TestKVHasNext(t *testing.T) {
 
 func TestKVTrailingGetUri(t *testing.T) {
 	f := func(path *Path) bool {
+		if path == nil {
+			return true
+		}
+		collection, key := url.PathEscape(path.Collection), url.PathEscape(path.Key)
 		if path.Ref == "" {
-			return path.trailingGetURI() == path.Collection+"/"+path.Key
+			return path.trailingGetURI() == collection+"/"+key
 		}
-		return path.trailingGetURI() == path.Collection+"/"+path.Key+"/refs/"+path.Ref
+		return path.trailingGetURI() == collection+"/"+key+"/refs/"+url.PathEscape(path.Ref)
 	}
 
 	if err := quick.Check(f, nil); err != nil {
@@ -32,10 +47,855 @@ func TestKVTrailingGetUri(t *testing.T) {
 
 func TestKVTrailingPutUri(t *testing.T) {
 	f := func(path *Path) bool {
-		return path.trailingPutURI() == path.Collection+"/"+path.Key
+		if path == nil {
+			return true
+		}
+		return path.trailingPutURI() == url.PathEscape(path.Collection)+"/"+url.PathEscape(path.Key)
 	}
 
 	if err := quick.Check(f, nil); err != nil {
 		t.Error(err)
 	}
 }
+
+func TestPutStream(t *testing.T) {
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("expected a JSON content type, got %q", ct)
+		}
+		if body, _ := ioutil.ReadAll(r.Body); string(body) != `{"name":"bob"}` {
+			t.Errorf("unexpected body %q", body)
+		}
+		w.Header().Set("Location", "/v0/users/bob/refs/0eb4e5dca3a2ec1b")
+		w.WriteHeader(201)
+	}))
+
+	path, err := c.PutStream("users", "bob", func(w io.Writer) error {
+		_, err := fmt.Fprint(w, `{"name":"bob"}`)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path.Ref != "0eb4e5dca3a2ec1b" {
+		t.Errorf("expected ref 0eb4e5dca3a2ec1b, got %q", path.Ref)
+	}
+}
+
+func TestPutStreamEncodeError(t *testing.T) {
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := ioutil.ReadAll(r.Body); err != nil {
+			w.WriteHeader(400)
+			return
+		}
+		w.Header().Set("Location", "/v0/users/bob/refs/0eb4e5dca3a2ec1b")
+		w.WriteHeader(201)
+	}))
+
+	_, err := c.PutStream("users", "bob", func(w io.Writer) error {
+		return fmt.Errorf("encoding failed")
+	})
+	if err == nil {
+		t.Fatal("expected the encoding error to abort the put")
+	}
+}
+
+func TestPutContentLength(t *testing.T) {
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.ContentLength != int64(len(body)) {
+			t.Errorf("expected a Content-Length of %d, got %d", len(body), r.ContentLength)
+		}
+		w.Header().Set("Location", "/v0/users/bob/refs/0eb4e5dca3a2ec1b")
+		w.WriteHeader(201)
+	}))
+
+	if _, err := c.Put("users", "bob", map[string]string{"name": "bob"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestListPrefix(t *testing.T) {
+	keys := []string{"tenant12", "tenant123:", "tenant123:a", "tenant123:\uffffx", "tenant123:\U0001f600", "tenant123;", "tenant124"}
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if end := query.Get("endKey"); end != "" {
+			t.Errorf("expected no endKey, got %q", end)
+		}
+		var results []string
+		for _, key := range keys {
+			if key >= query.Get("startKey") && key < query.Get("beforeKey") {
+				results = append(results, fmt.Sprintf(`{"path":{"collection":"users","key":%q},"value":{}}`, key))
+			}
+		}
+		fmt.Fprintf(w, `{"count":%d,"results":[%s]}`, len(results), strings.Join(results, ","))
+	}))
+
+	results, err := c.ListPrefix("users", "tenant123:", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var listed []string
+	for _, result := range results.Results {
+		listed = append(listed, result.Path.Key)
+	}
+	if expected := keys[1:5]; fmt.Sprintf("%q", listed) != fmt.Sprintf("%q", expected) {
+		t.Errorf("expected %q, got %q", expected, listed)
+	}
+}
+
+func TestPrefixSuccessor(t *testing.T) {
+	for prefix, expected := range map[string]string{
+		"":            "",
+		"a":           "b",
+		"tenant:":     "tenant;",
+		"a\uffff":     "a\U00010000",
+		"a\ud7ff":     "a\ue000",
+		"a\U0010ffff": "b",
+		"\U0010ffff":  "",
+		"a\U0001f600": "a\U0001f601",
+	} {
+		if successor := prefixSuccessor(prefix); successor != expected {
+			t.Errorf("expected the successor of %q to be %q, got %q", prefix, expected, successor)
+		}
+	}
+}
+
+func TestListTruncatedResponse(t *testing.T) {
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"count":2,"results":[{"path":{"collection":"users","key":"alice"},"value":{}},{"path":`)
+	}))
+
+	results, err := c.List("users", 2)
+	if results != nil {
+		t.Errorf("expected no results from a truncated response, got %+v", results)
+	}
+	if err == nil || !strings.Contains(err.Error(), "list results") {
+		t.Fatalf("expected an error describing the truncated list, got %v", err)
+	}
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("expected the error to wrap io.ErrUnexpectedEOF, got %v", err)
+	}
+}
+
+func TestQuoteRef(t *testing.T) {
+	for _, ref := range []string{
+		`0eb4e5dca3a2ec1b`,
+		`"0eb4e5dca3a2ec1b"`,
+		`W/"0eb4e5dca3a2ec1b"`,
+		` "0eb4e5dca3a2ec1b" `,
+	} {
+		if quoted := quoteRef(ref); quoted != `"0eb4e5dca3a2ec1b"` {
+			t.Errorf("quoteRef(%q) = %q, expected %q", ref, quoted, `"0eb4e5dca3a2ec1b"`)
+		}
+	}
+}
+
+func TestPutIfUnmodifiedPreQuotedRef(t *testing.T) {
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		if match := r.Header.Get("If-Match"); match != `"0eb4e5dca3a2ec1b"` {
+			t.Errorf("unexpected If-Match header %q", match)
+		}
+		w.Header().Set("Location", "/v0/users/bob/refs/82eafab14dc84ed3")
+		w.WriteHeader(201)
+	}))
+
+	path := &Path{Collection: "users", Key: "bob", Ref: `"0eb4e5dca3a2ec1b"`}
+	if _, err := c.PutIfUnmodified(path, map[string]string{"name": "bob"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "GET /v0/templates/basic":
+			w.Header().Set("Content-Location", "/v0/templates/basic/refs/0eb4e5dca3a2ec1b")
+			fmt.Fprint(w, `{"plan":"basic"}`)
+		case "PUT /v0/accounts/bob":
+			if body, _ := ioutil.ReadAll(r.Body); string(body) != `{"plan":"basic"}` {
+				t.Errorf("unexpected body %q", body)
+			}
+			w.Header().Set("Location", "/v0/accounts/bob/refs/82eafab14dc84ed3")
+			w.WriteHeader(201)
+		default:
+			w.WriteHeader(404)
+			fmt.Fprint(w, `{"message":"The requested items could not be found."}`)
+		}
+	}))
+
+	path, err := c.Copy("templates", "basic", "accounts", "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path.Collection != "accounts" || path.Key != "bob" || path.Ref != "82eafab14dc84ed3" {
+		t.Errorf("unexpected path %+v", path)
+	}
+
+	if _, err := c.Copy("templates", "missing", "accounts", "bob"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestKVResultRaw(t *testing.T) {
+	result := &KVResult{RawValue: []byte(`{"name":"bob"}`)}
+
+	raw := result.Raw()
+	if string(raw) != `{"name":"bob"}` {
+		t.Errorf("unexpected raw value %q", raw)
+	}
+
+	raw[0] = '['
+	if string(result.RawValue) != `{"name":"bob"}` {
+		t.Error("expected Raw to return a copy")
+	}
+}
+
+func TestListInto(t *testing.T) {
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"count":1,"results":[{"path":{"collection":"users","key":"bob"},"value":{}}]}`)
+	}))
+
+	results := &KVResults{
+		Results: make([]KVResult, 2, 8),
+		Next:    "/v0/users?limit=2&afterKey=alice",
+	}
+	results.Results[0].Path.Ref = "stale"
+	backing := &results.Results[:1][0]
+
+	if err := c.ListInto("users", 2, results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results.Results) != 1 || cap(results.Results) != 8 || &results.Results[0] != backing {
+		t.Errorf("expected the results slice to be reused, got len %d cap %d", len(results.Results), cap(results.Results))
+	}
+	if results.Results[0].Path.Key != "bob" || results.Results[0].Path.Ref != "" {
+		t.Errorf("unexpected result %+v", results.Results[0])
+	}
+	if results.HasNext() {
+		t.Error("expected the previous next link to be cleared")
+	}
+}
+
+func TestPathWithRef(t *testing.T) {
+	base := NewPath("users", "bob")
+	path := base.WithRef(`W/"0eb4e5dca3a2ec1b"`)
+
+	if *path != (Path{Collection: "users", Key: "bob", Ref: "0eb4e5dca3a2ec1b"}) {
+		t.Errorf("unexpected path %+v", path)
+	}
+	if base.Ref != "" {
+		t.Error("expected WithRef to leave the original path unmodified")
+	}
+}
+
+func TestRestore(t *testing.T) {
+	put := ""
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "GET /v0/users/bob/refs/":
+			if r.URL.Query().Get("values") != "true" {
+				t.Error("expected the history to be listed with values")
+			}
+			if r.URL.Query().Get("offset") == "" {
+				fmt.Fprint(w, `{"count":1,"results":[{"path":{"collection":"users","key":"bob","ref":"c","tombstone":true}}],"next":"/v0/users/bob/refs/?values=true&limit=10&offset=1"}`)
+				return
+			}
+			fmt.Fprint(w, `{"count":2,"results":[{"path":{"collection":"users","key":"bob","ref":"b"},"value":{"name":"bob"}},{"path":{"collection":"users","key":"bob","ref":"a"},"value":{"name":"robert"}}]}`)
+		case "PUT /v0/users/bob":
+			body, _ := ioutil.ReadAll(r.Body)
+			put = string(body)
+			w.Header().Set("Location", "/v0/users/bob/refs/d")
+			w.WriteHeader(201)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+	}))
+
+	path, err := c.Restore("users", "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path.Ref != "d" || put != `{"name":"bob"}` {
+		t.Errorf("expected the latest live value to be restored, got %+v with %q", path, put)
+	}
+}
+
+func TestRestorePurged(t *testing.T) {
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"count":1,"results":[{"path":{"collection":"users","key":"bob","ref":"c","tombstone":true}}]}`)
+	}))
+
+	if _, err := c.Restore("users", "bob"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestGetRefOnly(t *testing.T) {
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "HEAD" {
+			t.Errorf("expected a HEAD, got %s", r.Method)
+		}
+		switch r.URL.Path {
+		case "/v0/users/bob":
+			w.Header().Set("ETag", `"0eb4e5dca3a2ec1b"`)
+		case "/v0/users/alice":
+			w.Header().Set("Content-Location", "/v0/users/alice/refs/82eafab14dc84ed3")
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+
+	if ref, err := c.GetRefOnly("users", "bob"); err != nil || ref != "0eb4e5dca3a2ec1b" {
+		t.Errorf("GetRefOnly(bob) = %q, %v", ref, err)
+	}
+	if ref, err := c.GetRefOnly("users", "alice"); err != nil || ref != "82eafab14dc84ed3" {
+		t.Errorf("GetRefOnly(alice) = %q, %v", ref, err)
+	}
+	if _, err := c.GetRefOnly("users", "carol"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestParseRefLocationBaseURL(t *testing.T) {
+	c := NewClientWithOptions("token",
+		WithBaseURL("https://proxy.example.com/orchestrate/v1/"),
+		WithTransport(serve(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "PUT" {
+				w.Header().Set("Location", "/orchestrate/v1/users/bob/refs/82eafab14dc84ed3")
+				w.WriteHeader(201)
+				return
+			}
+			w.Header().Set("Content-Location", "https://proxy.example.com/orchestrate/v1/users/bob/refs/82eafab14dc84ed3")
+			fmt.Fprint(w, `{}`)
+		})))
+
+	path, err := c.Put("users", "bob", map[string]string{"name": "bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path.Ref != "82eafab14dc84ed3" {
+		t.Errorf("unexpected ref %q from a write", path.Ref)
+	}
+
+	result, err := c.Get("users", "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Path.Ref != "82eafab14dc84ed3" {
+		t.Errorf("unexpected ref %q from a read", result.Path.Ref)
+	}
+}
+
+func TestParseRefLocationMalformed(t *testing.T) {
+	for _, location := range []string{"", "/v0/users/bob", "/v0/users/bob/refs/", "/v0/users/bob/events/x"} {
+		if _, err := parseRefLocation(location); err == nil {
+			t.Errorf("expected an error parsing %q", location)
+		}
+	}
+}
+
+func TestListRefs(t *testing.T) {
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v0/users/bob/refs/" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if query := r.URL.Query(); query.Get("limit") != "3" || query.Get("offset") != "1" || query.Get("values") != "true" {
+			t.Errorf("unexpected query %q", r.URL.RawQuery)
+		}
+		fmt.Fprint(w, `{"count":3,"results":[`+
+			`{"path":{"collection":"users","key":"bob","ref":"c","tombstone":true}},`+
+			`{"path":{"collection":"users","key":"bob","ref":"b"},"value":{"name":"bob"}},`+
+			`{"path":{"collection":"users","key":"bob","ref":"a"},"value":{"name":"robert"}}]}`)
+	}))
+
+	tests := map[TombstoneFilter]string{
+		AllRefs:       "cba",
+		LiveRefs:      "ba",
+		TombstoneRefs: "c",
+	}
+
+	for filter, expected := range tests {
+		opts := RefListOptions{Limit: 3, Offset: 1, Values: true, Tombstones: filter}
+		results, err := c.ListRefs("users", "bob", opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		refs := ""
+		for _, result := range results.Results {
+			refs += result.Path.Ref
+		}
+		if refs != expected || results.Count != uint64(len(expected)) {
+			t.Errorf("filter %d: expected refs %q, got %q (count %d)", filter, expected, refs, results.Count)
+		}
+	}
+}
+
+func TestListRefsGetNext(t *testing.T) {
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("offset") == "2" {
+			fmt.Fprint(w, `{"count":2,"results":[`+
+				`{"path":{"collection":"users","key":"bob","ref":"b","tombstone":true}},`+
+				`{"path":{"collection":"users","key":"bob","ref":"a"}}]}`)
+			return
+		}
+		if r.URL.RawQuery != "" {
+			t.Errorf("expected no query for the default options, got %q", r.URL.RawQuery)
+		}
+		fmt.Fprint(w, `{"count":2,"results":[`+
+			`{"path":{"collection":"users","key":"bob","ref":"d"}},`+
+			`{"path":{"collection":"users","key":"bob","ref":"c"}}],`+
+			`"next":"/v0/users/bob/refs/?limit=2&offset=2"}`)
+	}))
+
+	opts := RefListOptions{Tombstones: LiveRefs}
+	results, err := c.ListRefs("users", "bob", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !results.HasNext() || len(results.Results) != 2 {
+		t.Fatalf("unexpected first page %+v", results)
+	}
+
+	results, err = c.ListRefsGetNext(results, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results.Results) != 1 || results.Results[0].Path.Ref != "a" {
+		t.Errorf("expected the tombstone to be filtered from the next page, got %+v", results.Results)
+	}
+}
+
+func TestPreserveNumbers(t *testing.T) {
+	const large = "1152921504606846977" // 2^60 + 1, beyond float64 precision
+
+	stored := ""
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PUT" {
+			body, _ := ioutil.ReadAll(r.Body)
+			stored = string(body)
+			w.Header().Set("Location", "/v0/accounts/a/refs/0eb4e5dca3a2ec1b")
+			w.WriteHeader(201)
+			return
+		}
+		w.Header().Set("Content-Location", "/v0/accounts/a/refs/0eb4e5dca3a2ec1b")
+		fmt.Fprint(w, stored)
+	}))
+
+	if _, err := c.PutPreserveNumbers("accounts", "a", []byte(`{ "id": `+large+` }`)); err != nil {
+		t.Fatal(err)
+	}
+	if stored != `{"id":`+large+`}` {
+		t.Fatalf("expected the number to be stored exactly, got %q", stored)
+	}
+
+	result, err := c.Get("accounts", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var value map[string]interface{}
+	if err := result.ValuePreserveNumbers(&value); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Put("accounts", "a", value); err != nil {
+		t.Fatal(err)
+	}
+	if stored != `{"id":`+large+`}`+"\n" {
+		t.Errorf("expected the number to survive a round trip, got %q", stored)
+	}
+
+	if _, err := c.PutPreserveNumbers("accounts", "a", []byte(`{"id":`)); err == nil {
+		t.Error("expected invalid JSON to be rejected")
+	}
+}
+
+func TestGetStream(t *testing.T) {
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v0/files/big" {
+			w.WriteHeader(404)
+			fmt.Fprint(w, `{"message":"not found"}`)
+			return
+		}
+		w.Header().Set("ETag", `"0eb4e5dca3a2ec1b"`)
+		fmt.Fprint(w, `"aGVsbG8="`)
+	}))
+
+	body, path, err := c.GetStream("files", "big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `"aGVsbG8="` || path.Ref != "0eb4e5dca3a2ec1b" || path.Key != "big" {
+		t.Errorf("unexpected stream %q at %+v", data, path)
+	}
+
+	if _, _, err := c.GetStream("files", "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestEnsureCollection(t *testing.T) {
+	var requests []string
+	keys := map[string]string{"full": `{"path":{"collection":"full","key":"a"},"value":{}}`}
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch r.Method {
+		case "GET":
+			collection := strings.TrimPrefix(r.URL.Path, "/v0/")
+			fmt.Fprintf(w, `{"count":0,"results":[%s]}`, keys[collection])
+		case "PUT":
+			if r.Header.Get("If-None-Match") != `"*"` {
+				t.Error("expected the sentinel to be written only if absent")
+			}
+			w.Header().Set("Location", "/v0/empty/.gorc-ensure-collection/refs/1")
+			w.WriteHeader(201)
+		case "DELETE":
+			if r.URL.Query().Get("purge") != "true" {
+				t.Error("expected the sentinel to be purged")
+			}
+			w.WriteHeader(204)
+		}
+	}))
+
+	if err := c.EnsureCollection("full"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.EnsureCollection("empty"); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "GET /v0/full GET /v0/empty PUT /v0/empty/.gorc-ensure-collection DELETE /v0/empty/.gorc-ensure-collection"
+	if actual := strings.Join(requests, " "); actual != expected {
+		t.Errorf("unexpected requests %q", actual)
+	}
+}
+
+func TestPutWithEncoder(t *testing.T) {
+	stored := ""
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		stored = string(body)
+		w.Header().Set("Location", "/v0/pages/a/refs/0eb4e5dca3a2ec1b")
+		w.WriteHeader(201)
+	}))
+
+	value := map[string]string{"html": "<b>a & b</b>"}
+	_, err := c.PutWithEncoder("pages", "a", value, func(e *json.Encoder) {
+		e.SetEscapeHTML(false)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored != `{"html":"<b>a & b</b>"}`+"\n" {
+		t.Errorf("expected HTML to be left unescaped, got %q", stored)
+	}
+}
+
+func TestPutIfUnmodifiedConflict(t *testing.T) {
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"82eafab14dc84ed3"`)
+		w.WriteHeader(412)
+		fmt.Fprint(w, `{"message":"The specified ref does not match the current ref"}`)
+	}))
+
+	_, err := c.PutIfUnmodified(NewPath("users", "bob").WithRef("0eb4e5dca3a2ec1b"), map[string]string{})
+	if !errors.Is(err, ErrPreconditionFailed) {
+		t.Fatalf("expected ErrPreconditionFailed, got %v", err)
+	}
+
+	var oe *OrchestrateError
+	if !errors.As(err, &oe) || oe.CurrentRef != "82eafab14dc84ed3" {
+		t.Errorf("expected the current ref to be reported, got %+v", oe)
+	}
+}
+
+func TestKVResultsEach(t *testing.T) {
+	results := &KVResults{Results: []KVResult{
+		{Path: Path{Key: "a"}, RawValue: []byte(`{"n":1}`)},
+		{Path: Path{Key: "b"}, RawValue: []byte(`{"n":2}`)},
+		{Path: Path{Key: "c"}, RawValue: []byte(`not json`)},
+	}}
+
+	var visited []string
+	err := results.Each(func(r *KVResult) (bool, error) {
+		visited = append(visited, r.Path.Key)
+		return r.Path.Key == "b", nil
+	})
+	if err != nil || strings.Join(visited, "") != "ab" {
+		t.Errorf("expected to stop after b, visited %q with %v", visited, err)
+	}
+
+	err = results.Each(func(r *KVResult) (bool, error) {
+		var value struct{ N int }
+		return false, r.Value(&value)
+	})
+	if err == nil {
+		t.Error("expected the decoding error to be returned")
+	}
+}
+
+func TestDeleteIfValue(t *testing.T) {
+	current, deleted := `{"b": [1, 2], "a": "x"}`, false
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			w.Header().Set("ETag", `"0eb4e5dca3a2ec1b"`)
+			fmt.Fprint(w, current)
+		case "DELETE":
+			if r.Header.Get("If-Match") != `"0eb4e5dca3a2ec1b"` {
+				t.Errorf("expected the delete to be conditional, got %q", r.Header.Get("If-Match"))
+			}
+			deleted = true
+			w.WriteHeader(204)
+		}
+	}))
+
+	if err := c.DeleteIfValue("users", "bob", map[string]interface{}{"a": "y", "b": []int{1, 2}}); err != ErrValueChanged {
+		t.Errorf("expected ErrValueChanged, got %v", err)
+	}
+	if deleted {
+		t.Fatal("expected a different value not to be deleted")
+	}
+
+	if err := c.DeleteIfValue("users", "bob", map[string]interface{}{"a": "x", "b": []int{1, 2}}); err != nil {
+		t.Fatal(err)
+	}
+	if !deleted {
+		t.Error("expected an equal value to be deleted")
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	value, ref, conflicts := "", "", 1
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			if value == "" {
+				w.WriteHeader(404)
+				fmt.Fprint(w, `{"message":"not found"}`)
+				return
+			}
+			w.Header().Set("ETag", `"`+ref+`"`)
+			fmt.Fprint(w, value)
+		case "PUT":
+			if match := r.Header.Get("If-Match"); match != "" && conflicts > 0 {
+				// Another writer slips in between the read and the write.
+				conflicts--
+				value, ref = `{"n":10}`, ref+"x"
+				w.WriteHeader(412)
+				fmt.Fprint(w, `{"message":"ref mismatch"}`)
+				return
+			}
+			body, _ := ioutil.ReadAll(r.Body)
+			value, ref = string(body), ref+"a"
+			w.Header().Set("Location", "/v0/counters/c/refs/"+ref)
+			w.WriteHeader(201)
+		}
+	}))
+
+	increment := func(current json.RawMessage) (interface{}, error) {
+		counter := struct{ N int }{}
+		if current != nil {
+			if err := json.Unmarshal(current, &counter); err != nil {
+				return nil, err
+			}
+		}
+		counter.N++
+		return map[string]int{"n": counter.N}, nil
+	}
+
+	if _, err := c.Update("counters", "c", increment, 0); err != nil {
+		t.Fatal(err)
+	}
+	if value != `{"n":1}`+"\n" {
+		t.Fatalf("expected an absent key to be created, got %q", value)
+	}
+
+	path, err := c.Update("counters", "c", increment, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != `{"n":11}`+"\n" || path.Ref != ref {
+		t.Errorf("expected the update to be retried against the new value, got %q at %+v", value, path)
+	}
+
+	conflicts = 1
+	if _, err := c.Update("counters", "c", increment, 0); !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("expected ErrPreconditionFailed once retries run out, got %v", err)
+	}
+}
+
+func TestPathString(t *testing.T) {
+	f := func(collection, key, ref string) bool {
+		if collection == "" || key == "" {
+			return true
+		}
+		path := &Path{Collection: collection, Key: key, Ref: ref}
+		parsed, err := ParsePath(path.String())
+		return err == nil && *parsed == *path
+	}
+
+	if err := quick.Check(f, nil); err != nil {
+		t.Error(err)
+	}
+
+	if s := (&Path{Collection: "files", Key: "a/b", Ref: "0eb4e5dca3a2ec1b"}).String(); s != "files/a%2Fb/0eb4e5dca3a2ec1b" {
+		t.Errorf("unexpected composite form %q", s)
+	}
+
+	for _, s := range []string{"", "users", "users/", "/bob", "users/bob/ref/extra", "users/%zz"} {
+		if _, err := ParsePath(s); err == nil {
+			t.Errorf("expected an error parsing %q", s)
+		}
+	}
+}
+
+func TestGetContentType(t *testing.T) {
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("ETag", `"0eb4e5dca3a2ec1b"`)
+		fmt.Fprint(w, "plain text")
+	}))
+
+	result, err := c.Get("notes", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ContentType != "text/plain" || string(result.RawValue) != "plain text" {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
+
+func TestGetRefOrLatest(t *testing.T) {
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v0/users/bob/refs/a":
+			fmt.Fprint(w, `{"v":"a"}`)
+		case "/v0/users/bob":
+			w.Header().Set("ETag", `"c"`)
+			fmt.Fprint(w, `{"v":"c"}`)
+		default:
+			w.WriteHeader(404)
+			fmt.Fprint(w, `{"message":"not found"}`)
+		}
+	}))
+
+	tests := []struct {
+		key, ref, value string
+		exact           bool
+	}{
+		{"bob", "a", `{"v":"a"}`, true},
+		{"bob", "purged", `{"v":"c"}`, false},
+	}
+
+	for _, test := range tests {
+		result, exact, err := c.GetRefOrLatest("users", test.key, test.ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(result.RawValue) != test.value || exact != test.exact {
+			t.Errorf("GetRefOrLatest(%s) = %s, %v", test.ref, result.RawValue, exact)
+		}
+	}
+
+	if _, _, err := c.GetRefOrLatest("users", "carol", "a"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestGetIfModified(t *testing.T) {
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v0/users/bob" {
+			t.Errorf("expected the current value to be requested, got %s", r.URL.Path)
+		}
+		if r.Header.Get("If-None-Match") == `"r2"` {
+			w.WriteHeader(304)
+			return
+		}
+		w.Header().Set("ETag", `"r2"`)
+		fmt.Fprint(w, `{"name":"bob"}`)
+	}))
+
+	result, modified, err := c.GetIfModified(NewPath("users", "bob").WithRef("r1"))
+	if err != nil || !modified || result.Path.Ref != "r2" || string(result.RawValue) != `{"name":"bob"}` {
+		t.Fatalf("expected the changed value, got %+v, %v, %v", result, modified, err)
+	}
+
+	result, modified, err = c.GetIfModified(&result.Path)
+	if err != nil || modified || result != nil {
+		t.Errorf("expected no change, got %+v, %v, %v", result, modified, err)
+	}
+
+	if _, modified, err := c.GetIfModified(NewPath("users", "bob")); err != nil || !modified {
+		t.Errorf("expected a path without a ref to be read, got %v, %v", modified, err)
+	}
+}
+
+func TestMultiGet(t *testing.T) {
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(r.URL.Path, "/v0/users/")
+		if key == "missing" {
+			w.WriteHeader(404)
+			fmt.Fprint(w, `{"message":"not found"}`)
+			return
+		}
+		w.Header().Set("ETag", `"`+key+`-ref"`)
+		fmt.Fprintf(w, `{"name":%q}`, key)
+	}))
+
+	results, err := c.MultiGet("users", []string{"a", "missing", "b"}, 2)
+
+	var merr *MultiGetError
+	if !errors.As(err, &merr) || len(merr.Errors) != 3 || !errors.Is(merr.Errors[1], ErrNotFound) ||
+		merr.Errors[0] != nil || merr.Errors[2] != nil {
+		t.Fatalf("expected only the missing key to fail, got %v", err)
+	}
+	if len(results) != 3 || results[1] != nil ||
+		string(results[0].RawValue) != `{"name":"a"}` || results[2].Path.Ref != "b-ref" {
+		t.Errorf("unexpected results %+v", results)
+	}
+
+	if _, err := c.MultiGet("users", []string{"a", "b"}, 0); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
+
+func TestListRange(t *testing.T) {
+	var query string
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		fmt.Fprint(w, `{"count":0,"results":[]}`)
+	}))
+
+	tests := []struct {
+		opts     ListRangeOptions
+		expected string
+	}{
+		{ListRangeOptions{Limit: 10, StartKey: "a", EndKey: "m"}, "endKey=m&limit=10&startKey=a"},
+		{ListRangeOptions{Limit: 5, AfterKey: "a", BeforeKey: "m"}, "afterKey=a&beforeKey=m&limit=5"},
+		{ListRangeOptions{Limit: 5, BeforeKey: "m"}, "beforeKey=m&limit=5"},
+	}
+
+	for _, test := range tests {
+		if _, err := c.ListRange("users", test.opts); err != nil {
+			t.Fatal(err)
+		}
+		if query != test.expected {
+			t.Errorf("expected query %q, got %q", test.expected, query)
+		}
+	}
+
+	if _, err := c.ListRange("users", ListRangeOptions{StartKey: "a", AfterKey: "b"}); err == nil {
+		t.Error("expected an error for two lower bounds")
+	}
+	if _, err := c.ListRange("users", ListRangeOptions{EndKey: "a", BeforeKey: "b"}); err == nil {
+		t.Error("expected an error for two upper bounds")
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+import (
+	"net/http"
+	"sync"
+)
+
+// Details of a response from Orchestrate that operations such as PutEvent,
+// PutRelation and Delete do not otherwise return.
+type ResponseMeta struct {
+	// The HTTP status code of the response.
+	StatusCode int
+
+	// The Location header of the response, if any.
+	Location string
+
+	// The ref the response reported, from its ETag, Content-Location or
+	// Location header, if any.
+	Ref string
+}
+
+// Holds the details of the most recent response a Client received.
+type lastResponse struct {
+	mu   sync.Mutex
+	meta ResponseMeta
+}
+
+// Returns the details of the most recent response received by this Client,
+// or by any Client derived from it with WithAuthToken or WithHeaders, with
+// the zero ResponseMeta if there has been none. This includes responses
+// reporting an error. When the Client is used from several goroutines at
+// once, the response is that of whichever request finished last, so this is
+// only meaningful when operations are made one at a time.
+func (c *Client) LastResponseMeta() ResponseMeta {
+	if c.last == nil {
+		return ResponseMeta{}
+	}
+
+	c.last.mu.Lock()
+	defer c.last.mu.Unlock()
+	return c.last.meta
+}
+
+// Records the details of a response as the most recent.
+func (c *Client) recordResponse(resp *http.Response) {
+	if c.last == nil {
+		return
+	}
+
+	meta := ResponseMeta{
+		StatusCode: resp.StatusCode,
+		Location:   resp.Header.Get("Location"),
+	}
+	if ref, err := refFromHeader(resp.Header, c.baseURL); err == nil {
+		meta.Ref = ref
+	} else if ref, err := parseRefLocation(meta.Location, c.baseURL); err == nil {
+		meta.Ref = ref
+	}
+
+	c.last.mu.Lock()
+	defer c.last.mu.Unlock()
+	c.last.meta = meta
+}
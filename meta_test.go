@@ -0,0 +1,48 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestLastResponseMeta(t *testing.T) {
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "PUT":
+			w.Header().Set("Location", "/v0/users/bob/events/login/1398286518286/6")
+			w.WriteHeader(204)
+		case "DELETE":
+			w.WriteHeader(204)
+		default:
+			w.Header().Set("Location", "/v0/users/bob/refs/0eb4e5dca3a2ec1b")
+			w.WriteHeader(201)
+		}
+	}))
+
+	if meta := c.LastResponseMeta(); meta != (ResponseMeta{}) {
+		t.Errorf("expected no response yet, got %+v", meta)
+	}
+
+	if err := c.PutEvent("users", "bob", "login", map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	if meta := c.LastResponseMeta(); meta.StatusCode != 204 || meta.Location != "/v0/users/bob/events/login/1398286518286/6" || meta.Ref != "" {
+		t.Errorf("unexpected meta after PutEvent %+v", meta)
+	}
+
+	if _, err := c.WithHeaders(nil).Increment("users", "bob", "visits", 1); err != nil {
+		t.Fatal(err)
+	}
+	if meta := c.LastResponseMeta(); meta.StatusCode != 201 || meta.Ref != "0eb4e5dca3a2ec1b" {
+		t.Errorf("unexpected meta after a write from a derived client %+v", meta)
+	}
+
+	if err := c.Delete("users", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if meta := c.LastResponseMeta(); meta.StatusCode != 204 || meta.Location != "" {
+		t.Errorf("unexpected meta after Delete %+v", meta)
+	}
+}
@@ -0,0 +1,246 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// A single key/value record of newline delimited JSON, as in
+// {"key":"bob","value":{"name":"Bob"}}.
+type ndjsonRecord struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+// Options controlling ImportResumable.
+type ImportOptions struct {
+	// Skip every record up to and including the one with this key, as
+	// previously reported to Checkpoint. If empty, nothing is skipped.
+	ResumeAfter string
+
+	// Called with the key of the last record written, after every
+	// CheckpointEvery records and again when the import stops, whether it
+	// completed or failed. May be nil.
+	Checkpoint func(key string)
+
+	// How many records to write between checkpoints. Zero means only
+	// checkpoint when the import stops.
+	CheckpointEvery int
+}
+
+// Store newline delimited JSON records read from r, one key/value record per
+// line, to a collection. Records are written in order with Put, stopping at
+// the first one that can not be parsed or written, and the number written is
+// returned. The checkpoints reported through opts can be passed back as
+// ResumeAfter to restart an interrupted import where it left off; this relies
+// on r producing the same records in the same order each time.
+func (c *Client) ImportResumable(collection string, r io.Reader, opts ImportOptions) (int, error) {
+	lines := newLineReader(r)
+	skipping := opts.ResumeAfter != ""
+	written, last := 0, ""
+
+	checkpoint := func() {
+		if opts.Checkpoint != nil && last != "" {
+			opts.Checkpoint(last)
+		}
+	}
+	defer checkpoint()
+
+	for {
+		record, err := lines.next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return written, err
+		}
+
+		if skipping {
+			skipping = record.Key != opts.ResumeAfter
+			continue
+		}
+
+		if _, err := c.PutRaw(collection, record.Key, bytes.NewReader(record.Value)); err != nil {
+			return written, fmt.Errorf("line %d: %w", lines.line, err)
+		}
+
+		written++
+		last = record.Key
+		if opts.CheckpointEvery > 0 && written%opts.CheckpointEvery == 0 {
+			checkpoint()
+		}
+	}
+
+	if skipping {
+		return written, fmt.Errorf("resume key %q not found", opts.ResumeAfter)
+	}
+
+	return written, nil
+}
+
+// Write every value in a collection to w as newline delimited JSON, one
+// key/value record per line in key order, as read by ImportResumable, and
+// return the number written. The collection is read a page of pageSize values
+// at a time, each page reusing the memory of the last, so memory use does not
+// grow with the size of the collection. Export stops at the first error, from
+// reading or writing.
+func (c *Client) Export(collection string, w io.Writer, pageSize int) (int, error) {
+	encoder := json.NewEncoder(w)
+	results := new(KVResults)
+	written := 0
+
+	if err := c.ListInto(collection, pageSize, results); err != nil {
+		return 0, err
+	}
+
+	for {
+		for _, result := range results.Results {
+			record := ndjsonRecord{Key: result.Path.Key, Value: result.RawValue}
+			if err := encoder.Encode(record); err != nil {
+				return written, err
+			}
+			written++
+		}
+
+		if !results.HasNext() {
+			return written, nil
+		}
+		if err := c.Follow(results.Next, results); err != nil {
+			return written, err
+		}
+	}
+}
+
+// Store newline delimited JSON records read from r, one key/value record per
+// line as written by Export, to a collection, making at most concurrency
+// writes at once. Records are written in no particular order and neither a
+// line that can not be parsed nor a failed write stops the rest of the import.
+// The number of records written is returned along with an error for each line
+// that was not, in line order and prefixed with its line number. Only failing
+// to read from r ends the import early, and is reported last.
+func (c *Client) Import(collection string, r io.Reader, concurrency int) (int, []error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	type job struct {
+		line   int
+		record *ndjsonRecord
+	}
+
+	var (
+		mu      sync.Mutex
+		written int
+		failed  = map[int]error{}
+		wg      sync.WaitGroup
+		jobs    = make(chan job)
+	)
+
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				_, err := c.PutRaw(collection, j.record.Key, bytes.NewReader(j.record.Value))
+
+				mu.Lock()
+				if err != nil {
+					failed[j.line] = fmt.Errorf("line %d: %w", j.line, err)
+				} else {
+					written++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	lines := newLineReader(r)
+	var readErr error
+	for {
+		data, err := lines.nextLine()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			readErr = err
+			break
+		}
+
+		record, err := lines.parse(data)
+		if err != nil {
+			mu.Lock()
+			failed[lines.line] = err
+			mu.Unlock()
+			continue
+		}
+		jobs <- job{line: lines.line, record: record}
+	}
+	close(jobs)
+	wg.Wait()
+
+	var errs []error
+	for line := 1; line <= lines.line; line++ {
+		if err, ok := failed[line]; ok {
+			errs = append(errs, err)
+		}
+	}
+	if readErr != nil {
+		errs = append(errs, readErr)
+	}
+
+	return written, errs
+}
+
+// Reads key/value records from newline delimited JSON, skipping blank lines.
+type lineReader struct {
+	reader *bufio.Reader
+	line   int
+}
+
+func newLineReader(r io.Reader) *lineReader {
+	return &lineReader{reader: bufio.NewReader(r)}
+}
+
+// Returns the next record, or io.EOF once there are none left. A line that
+// can not be parsed is reported with its line number.
+func (lr *lineReader) next() (*ndjsonRecord, error) {
+	data, err := lr.nextLine()
+	if err != nil {
+		return nil, err
+	}
+	return lr.parse(data)
+}
+
+// Returns the next line that is not blank, or io.EOF once there are none left.
+func (lr *lineReader) nextLine() ([]byte, error) {
+	for {
+		data, err := lr.reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if len(data) == 0 && err == io.EOF {
+			return nil, io.EOF
+		}
+		lr.line++
+
+		if data = bytes.TrimSpace(data); len(data) != 0 {
+			return data, nil
+		}
+	}
+}
+
+// Parses the line last returned by nextLine as a record.
+func (lr *lineReader) parse(data []byte) (*ndjsonRecord, error) {
+	record := new(ndjsonRecord)
+	if err := json.Unmarshal(data, record); err != nil {
+		return nil, fmt.Errorf("line %d: %w", lr.line, err)
+	}
+	if record.Key == "" || len(record.Value) == 0 {
+		return nil, fmt.Errorf("line %d: record needs a key and a value", lr.line)
+	}
+	return record, nil
+}
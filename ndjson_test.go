@@ -0,0 +1,134 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const importData = `{"key":"a","value":{"n":1}}
+{"key":"b","value":{"n":2}}
+
+{"key":"c","value":{"n":3}}
+{"key":"d","value":{"n":4}}
+`
+
+// Returns a client that records the keys and bodies put to it, failing the
+// put of failKey.
+func newImportClient(t *testing.T, failKey string, puts map[string]string) *Client {
+	var mu sync.Mutex
+	return newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(r.URL.Path, "/v0/users/")
+		if key == failKey {
+			w.WriteHeader(500)
+			w.Write([]byte(`{"message":"failed"}`))
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		puts[key] = string(body)
+		mu.Unlock()
+		w.Header().Set("Location", "/v0/users/"+key+"/refs/1")
+		w.WriteHeader(201)
+	}))
+}
+
+func TestImportResumable(t *testing.T) {
+	puts := make(map[string]string)
+	c := newImportClient(t, "c", puts)
+
+	var checkpoints []string
+	opts := ImportOptions{
+		Checkpoint:      func(key string) { checkpoints = append(checkpoints, key) },
+		CheckpointEvery: 1,
+	}
+
+	written, err := c.ImportResumable("users", strings.NewReader(importData), opts)
+	if err == nil || !strings.Contains(err.Error(), "line 4") {
+		t.Fatalf("expected the failed put on line 4 to be reported, got %v", err)
+	}
+	if written != 2 || len(puts) != 2 || puts["b"] != `{"n":2}` {
+		t.Errorf("expected a and b to be written, got %d: %v", written, puts)
+	}
+	if last := checkpoints[len(checkpoints)-1]; last != "b" {
+		t.Errorf("expected the last checkpoint to be b, got %q", last)
+	}
+
+	resumed := make(map[string]string)
+	c = newImportClient(t, "", resumed)
+
+	written, err = c.ImportResumable("users", strings.NewReader(importData), ImportOptions{ResumeAfter: "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if written != 2 || len(resumed) != 2 || resumed["c"] != `{"n":3}` || resumed["d"] != `{"n":4}` {
+		t.Errorf("expected c and d to be written on resume, got %d: %v", written, resumed)
+	}
+}
+
+func TestImportResumableMalformed(t *testing.T) {
+	c := newImportClient(t, "", make(map[string]string))
+
+	_, err := c.ImportResumable("users", strings.NewReader("{\"key\":\"a\",\"value\":1}\n{\"key\":"), ImportOptions{})
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected the malformed line 2 to be reported, got %v", err)
+	}
+
+	_, err = c.ImportResumable("users", strings.NewReader(importData), ImportOptions{ResumeAfter: "z"})
+	if err == nil {
+		t.Error("expected an error for a resume key that never appears")
+	}
+}
+
+func TestImport(t *testing.T) {
+	puts := make(map[string]string)
+	c := newImportClient(t, "c", puts)
+
+	data := importData + "{\"key\":\n{\"key\":\"e\",\"value\":{\"n\":5}}\n"
+	written, errs := c.Import("users", strings.NewReader(data), 3)
+	if written != 4 || len(puts) != 4 || puts["e"] != `{"n":5}` {
+		t.Errorf("expected a, b, d and e to be written, got %d: %v", written, puts)
+	}
+	if len(errs) != 2 ||
+		!strings.HasPrefix(errs[0].Error(), "line 4:") ||
+		!strings.HasPrefix(errs[1].Error(), "line 6:") {
+		t.Errorf("expected the failed put on line 4 and malformed line 6, got %v", errs)
+	}
+
+	if written, errs := c.Import("users", strings.NewReader(importData[:28]), 0); written != 1 || errs != nil {
+		t.Errorf("expected one clean write, got %d: %v", written, errs)
+	}
+}
+
+func TestExport(t *testing.T) {
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("afterKey") == "b" {
+			fmt.Fprint(w, `{"count":1,"results":[{"path":{"collection":"users","key":"c"},"value":{"n":3}}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"count":2,"results":[`+
+			`{"path":{"collection":"users","key":"a"},"value":{"n":1}},`+
+			`{"path":{"collection":"users","key":"b"},"value":{"n":2}}],`+
+			`"next":"/v0/users?limit=2&afterKey=b"}`)
+	}))
+
+	var out bytes.Buffer
+	n, err := c.Export("users", &out, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"key":"a","value":{"n":1}}
+{"key":"b","value":{"n":2}}
+{"key":"c","value":{"n":3}}
+`
+	if n != 3 || out.String() != expected {
+		t.Errorf("exported %d records:\n%s", n, out.String())
+	}
+}
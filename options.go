@@ -0,0 +1,102 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// A configuration option for NewClientWithOptions.
+type Option func(*Client)
+
+// Returns a new Client object that will use the given authToken for
+// authorization against Orchestrate, configured by the given options. Options
+// are applied in order, so later options take precedence over earlier ones.
+func NewClientWithOptions(authToken string, opts ...Option) *Client {
+	c := NewClient(authToken)
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Send requests to the given root URL rather than the Orchestrate API itself,
+// for example to go through a proxy. The URL should include the API version,
+// as in "https://api.orchestrate.io/v0/".
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		c.baseURL = strings.TrimSuffix(baseURL, "/") + "/"
+	}
+}
+
+// Send requests to the Orchestrate API at the given endpoint, a scheme and
+// host such as "https://api.aws-eu-west-1.orchestrate.io", for accounts hosted
+// outside the default data center. The API version of the default base URL is
+// kept; use WithBaseURL to choose the whole URL.
+func WithEndpoint(endpoint string) Option {
+	return func(c *Client) {
+		c.baseURL = strings.TrimSuffix(endpoint, "/") + "/" + apiVersion(rootUri) + "/"
+	}
+}
+
+// Make requests with the given http.Client rather than one that uses
+// DefaultTransport.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// Make requests through the given transport rather than DefaultTransport, for
+// example one with its own dial and TLS handshake timeouts. The http.Client in
+// use is copied rather than modified, so one passed to WithHTTPClient is left
+// untouched, and a timeout set with WithTimeout is kept.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(c *Client) {
+		httpClient := *c.httpClient
+		httpClient.Transport = transport
+		c.httpClient = &httpClient
+	}
+}
+
+// Send the given User-Agent header with every request.
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) {
+		c.userAgent = userAgent
+	}
+}
+
+// Retry failed requests as described by SetRetries.
+func WithRetry(max int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.SetRetries(max, backoff)
+	}
+}
+
+// Limit the total time a single request may take, including reading the
+// response body. The http.Client in use is copied rather than modified, so
+// one passed to WithHTTPClient is left untouched.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		httpClient := *c.httpClient
+		httpClient.Timeout = timeout
+		c.httpClient = &httpClient
+	}
+}
+
+// Returned instead of making any request that could change data, from a
+// Client created with WithReadOnly.
+var ErrReadOnly = errors.New("client is read only")
+
+// Refuse every request that could change data, such as those made by the Put,
+// Delete, Patch, PutEvent and PutRelation families, returning ErrReadOnly
+// without sending anything. Only GET and HEAD requests are made. This holds
+// for every Client derived from this one with WithAuthToken or WithHeaders.
+func WithReadOnly() Option {
+	return func(c *Client) {
+		c.readOnly = true
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestNewClientWithOptions(t *testing.T) {
+	var requested string
+	var userAgent string
+	httpClient := &http.Client{Transport: serve(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.String()
+		userAgent = r.Header.Get("User-Agent")
+	})}
+
+	c := NewClientWithOptions("token",
+		WithHTTPClient(httpClient),
+		WithBaseURL("http://localhost:8080/v0"),
+		WithUserAgent("gorc-test"),
+		WithRetry(3, time.Second),
+		WithTimeout(5*time.Second),
+	)
+
+	if _, err := c.Exists("users", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if requested != "http://localhost:8080/v0/users/bob" {
+		t.Errorf("unexpected request URL %q", requested)
+	}
+	if userAgent != "gorc-test" {
+		t.Errorf("unexpected User-Agent %q", userAgent)
+	}
+	if c.maxRetries != 3 || c.retryBackoff != time.Second {
+		t.Errorf("retries not configured, got %d and %s", c.maxRetries, c.retryBackoff)
+	}
+	if c.httpClient.Timeout != 5*time.Second {
+		t.Errorf("expected a 5s timeout, got %s", c.httpClient.Timeout)
+	}
+	if httpClient.Timeout != 0 {
+		t.Error("expected the provided http.Client to be left unmodified")
+	}
+}
+
+func TestWithReadOnly(t *testing.T) {
+	var methods []string
+	c := NewClientWithOptions("token", WithReadOnly(), WithHTTPClient(&http.Client{Transport: serve(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		w.Header().Set("ETag", `"0eb4e5dca3a2ec1b"`)
+		fmt.Fprint(w, `{}`)
+	})}))
+
+	if _, err := c.Get("users", "bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	writes := map[string]error{
+		"Put":         func() error { _, err := c.Put("users", "bob", map[string]string{}); return err }(),
+		"Delete":      c.Delete("users", "bob"),
+		"Purge":       c.WithHeaders(nil).Purge("users", "bob"),
+		"PutEvent":    c.PutEvent("users", "bob", "login", map[string]string{}),
+		"PutRelation": c.PutRelation("users", "bob", "follows", "users", "alice"),
+		"Increment":   func() error { _, err := c.Increment("users", "bob", "n", 1); return err }(),
+	}
+	for name, err := range writes {
+		if err != ErrReadOnly {
+			t.Errorf("%s: expected ErrReadOnly, got %v", name, err)
+		}
+	}
+
+	if len(methods) != 1 || methods[0] != "GET" {
+		t.Errorf("expected only the GET to be sent, got %v", methods)
+	}
+}
+
+func TestWithEndpointAndTransport(t *testing.T) {
+	var requested string
+	transport := serve(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.String()
+	})
+
+	c := NewClientWithOptions("token",
+		WithTimeout(5*time.Second),
+		WithEndpoint("https://api.aws-eu-west-1.orchestrate.io/"),
+		WithTransport(transport),
+	)
+
+	if _, err := c.Exists("users", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if requested != "https://api.aws-eu-west-1.orchestrate.io/v0/users/bob" {
+		t.Errorf("unexpected request URL %q", requested)
+	}
+	if c.httpClient.Timeout != 5*time.Second {
+		t.Errorf("expected the timeout to be kept, got %s", c.httpClient.Timeout)
+	}
+}
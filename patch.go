@@ -0,0 +1,175 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// A single JSON Patch (RFC 6902) operation, as applied by Orchestrate to a
+// stored value. Orchestrate extends the standard operations with "inc", which
+// adds Value to the number at Path.
+type PatchOp struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	From  string      `json:"from,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// Encodes the operation, always including the value of the operations that
+// take one, so that a nil Value is sent as null rather than left out.
+func (op PatchOp) MarshalJSON() ([]byte, error) {
+	type patchOp PatchOp
+	switch op.Op {
+	case "add", "replace", "test", "inc":
+		return json.Marshal(struct {
+			patchOp
+			Value interface{} `json:"value"`
+		}{patchOp(op), op.Value})
+	}
+	return json.Marshal(patchOp(op))
+}
+
+// Returns an operation adding value at a field, replacing any value already
+// there. Nested fields are named with dots, as in "address.city", and the end
+// of an array is named with "-", as in "tags.-".
+func PatchAdd(field string, value interface{}) PatchOp {
+	return PatchOp{Op: "add", Path: fieldPointer(field), Value: value}
+}
+
+// Returns an operation removing a field.
+func PatchRemove(field string) PatchOp {
+	return PatchOp{Op: "remove", Path: fieldPointer(field)}
+}
+
+// Returns an operation replacing the value of a field, which must exist.
+func PatchReplace(field string, value interface{}) PatchOp {
+	return PatchOp{Op: "replace", Path: fieldPointer(field), Value: value}
+}
+
+// Returns an operation moving the value of one field to another.
+func PatchMove(from, to string) PatchOp {
+	return PatchOp{Op: "move", Path: fieldPointer(to), From: fieldPointer(from)}
+}
+
+// Returns an operation copying the value of one field to another.
+func PatchCopy(from, to string) PatchOp {
+	return PatchOp{Op: "copy", Path: fieldPointer(to), From: fieldPointer(from)}
+}
+
+// Returns an operation that fails the whole patch unless a field holds value.
+func PatchTest(field string, value interface{}) PatchOp {
+	return PatchOp{Op: "test", Path: fieldPointer(field), Value: value}
+}
+
+// Returns an operation adding delta to the number held in a field.
+func PatchInc(field string, delta float64) PatchOp {
+	return PatchOp{Op: "inc", Path: fieldPointer(field), Value: delta}
+}
+
+// Apply a JSON Patch to the value of a collection-key pair, returning the path
+// of the new value. The operations are applied by the server in order, and
+// either all of them take effect or, if any fails, none do.
+func (c *Client) Patch(collection, key string, ops []PatchOp) (*Path, error) {
+	return c.doPatch(&Path{Collection: collection, Key: key}, nil, ops)
+}
+
+// Add delta to the number held in a field of a collection-key pair's value,
+// returning the path of the new value. Nested fields are named with dots, as
+// in "stats.visits".
+func (c *Client) Increment(collection, key, field string, delta float64) (*Path, error) {
+	ops := []PatchOp{PatchInc(field, delta)}
+	return c.doPatch(&Path{Collection: collection, Key: key}, nil, ops)
+}
+
+// Subtract delta from the number held in a field of a collection-key pair's
+// value, returning the path of the new value.
+func (c *Client) Decrement(collection, key, field string, delta float64) (*Path, error) {
+	return c.Increment(collection, key, field, -delta)
+}
+
+// Append value to the array held in a field of a collection-key pair's value,
+// returning the path of the new value. The append is applied by the server,
+// so concurrent appends are never lost to a read-modify-write race.
+func (c *Client) AppendToArray(collection, key, field string, value interface{}) (*Path, error) {
+	ops := []PatchOp{PatchAdd(field+".-", value)}
+	return c.doPatch(&Path{Collection: collection, Key: key}, nil, ops)
+}
+
+// Like AppendToArray, except that the append is only applied if the path's
+// ref is the latest. Otherwise the returned error matches
+// ErrPreconditionFailed.
+func (c *Client) AppendToArrayIfUnmodified(path *Path, field string, value interface{}) (*Path, error) {
+	headers := map[string]string{
+		"If-Match": quoteRef(path.Ref),
+	}
+
+	ops := []PatchOp{PatchAdd(field+".-", value)}
+	return c.doPatch(path, headers, ops)
+}
+
+// Merge partial into the value of a collection-key pair following JSON Merge
+// Patch (RFC 7386), returning the path of the new value. Fields of partial
+// replace those of the stored value, objects are merged recursively and a
+// field set to null is removed. The merge is applied by the server, so fields
+// partial does not name are never overwritten by a stale copy.
+func (c *Client) MergePatch(collection, key string, partial interface{}) (*Path, error) {
+	return c.doMergePatch(&Path{Collection: collection, Key: key}, nil, partial)
+}
+
+// Like MergePatch, except that the merge is only applied if the path's ref is
+// the latest. Otherwise the returned error matches ErrPreconditionFailed.
+func (c *Client) MergePatchIfUnmodified(path *Path, partial interface{}) (*Path, error) {
+	headers := map[string]string{
+		"If-Match": quoteRef(path.Ref),
+	}
+
+	return c.doMergePatch(path, headers, partial)
+}
+
+// Execute a JSON Merge Patch.
+func (c *Client) doMergePatch(path *Path, headers map[string]string, partial interface{}) (*Path, error) {
+	buf, err := encodeJSON(partial)
+	if err != nil {
+		return nil, err
+	}
+
+	patchHeaders := map[string]string{
+		"Content-Type": "application/merge-patch+json",
+	}
+	for k, v := range headers {
+		patchHeaders[k] = v
+	}
+
+	return c.doWrite("PATCH", path, patchHeaders, buf)
+}
+
+// Execute a JSON Patch.
+func (c *Client) doPatch(path *Path, headers map[string]string, ops []PatchOp) (*Path, error) {
+	buf, err := encodeJSON(ops)
+	if err != nil {
+		return nil, err
+	}
+
+	patchHeaders := map[string]string{
+		"Content-Type": "application/json-patch+json",
+	}
+	for k, v := range headers {
+		patchHeaders[k] = v
+	}
+
+	return c.doWrite("PATCH", path, patchHeaders, buf)
+}
+
+// Converts a dotted field name, such as "a.b.c", to a JSON Pointer
+// (RFC 6901), such as "/a/b/c".
+func fieldPointer(field string) string {
+	escaper := strings.NewReplacer("~", "~0", "/", "~1")
+
+	parts := strings.Split(field, ".")
+	for i, part := range parts {
+		parts[i] = escaper.Replace(part)
+	}
+	return "/" + strings.Join(parts, "/")
+}
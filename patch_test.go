@@ -0,0 +1,160 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+func TestFieldPointer(t *testing.T) {
+	tests := map[string]string{
+		"visits":       "/visits",
+		"stats.visits": "/stats/visits",
+		"a.b.c":        "/a/b/c",
+		"a/b.c~d":      "/a~1b/c~0d",
+	}
+
+	for field, expected := range tests {
+		if actual := fieldPointer(field); actual != expected {
+			t.Errorf("fieldPointer(%q) = %q, expected %q", field, actual, expected)
+		}
+	}
+}
+
+func TestIncrement(t *testing.T) {
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PATCH" {
+			t.Errorf("expected a PATCH, got %s", r.Method)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/json-patch+json" {
+			t.Errorf("unexpected content type %q", ct)
+		}
+		if body, _ := ioutil.ReadAll(r.Body); string(body) != `[{"op":"inc","path":"/stats/visits","value":-2}]`+"\n" {
+			t.Errorf("unexpected body %q", body)
+		}
+		w.Header().Set("Location", "/v0/users/bob/refs/82eafab14dc84ed3")
+		w.WriteHeader(201)
+	}))
+
+	path, err := c.Decrement("users", "bob", "stats.visits", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path.Ref != "82eafab14dc84ed3" {
+		t.Errorf("unexpected ref %q", path.Ref)
+	}
+}
+
+func TestAppendToArray(t *testing.T) {
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		if body, _ := ioutil.ReadAll(r.Body); string(body) != `[{"op":"add","path":"/meta/tags/-","value":"new"}]`+"\n" {
+			t.Errorf("unexpected body %q", body)
+		}
+		if r.Header.Get("If-Match") == `"0eb4e5dca3a2ec1b"` {
+			w.WriteHeader(412)
+			fmt.Fprint(w, `{"message":"ref mismatch"}`)
+			return
+		}
+		w.Header().Set("Location", "/v0/users/bob/refs/82eafab14dc84ed3")
+		w.WriteHeader(201)
+	}))
+
+	path, err := c.AppendToArray("users", "bob", "meta.tags", "new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path.Ref != "82eafab14dc84ed3" {
+		t.Errorf("unexpected ref %q", path.Ref)
+	}
+
+	_, err = c.AppendToArrayIfUnmodified(NewPath("users", "bob").WithRef("0eb4e5dca3a2ec1b"), "meta.tags", "new")
+	if !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("expected ErrPreconditionFailed, got %v", err)
+	}
+}
+
+func TestPatch(t *testing.T) {
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		expected := `[{"op":"add","path":"/tags/-","value":"new"},` +
+			`{"op":"remove","path":"/old"},` +
+			`{"op":"replace","path":"/name","value":"bob"},` +
+			`{"op":"move","path":"/b","from":"/a"},` +
+			`{"op":"copy","path":"/d","from":"/c"},` +
+			`{"op":"test","path":"/version","value":3},` +
+			`{"op":"inc","path":"/stats/visits","value":1}]` + "\n"
+		if body, _ := ioutil.ReadAll(r.Body); string(body) != expected {
+			t.Errorf("unexpected body %s", body)
+		}
+		w.Header().Set("Location", "/v0/users/bob/refs/82eafab14dc84ed3")
+		w.WriteHeader(201)
+	}))
+
+	ops := []PatchOp{
+		PatchAdd("tags.-", "new"),
+		PatchRemove("old"),
+		PatchReplace("name", "bob"),
+		PatchMove("a", "b"),
+		PatchCopy("c", "d"),
+		PatchTest("version", 3),
+		PatchInc("stats.visits", 1),
+	}
+	if _, err := c.Patch("users", "bob", ops); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPatchNilValue(t *testing.T) {
+	ops := []PatchOp{PatchAdd("a", nil), PatchReplace("b", nil), PatchTest("c", nil), PatchRemove("d")}
+	expected := `[{"op":"add","path":"/a","value":null},` +
+		`{"op":"replace","path":"/b","value":null},` +
+		`{"op":"test","path":"/c","value":null},` +
+		`{"op":"remove","path":"/d"}]`
+	body, err := json.Marshal(ops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != expected {
+		t.Errorf("unexpected encoding %s", body)
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); r.Method != "PATCH" || ct != "application/merge-patch+json" {
+			t.Errorf("unexpected %s with content type %q", r.Method, ct)
+		}
+		if body, _ := ioutil.ReadAll(r.Body); string(body) != `{"address":{"city":"Lima"},"nickname":null}`+"\n" {
+			t.Errorf("unexpected body %q", body)
+		}
+		if r.Header.Get("If-Match") == `"stale"` {
+			w.WriteHeader(412)
+			fmt.Fprint(w, `{"message":"ref does not match"}`)
+			return
+		}
+		w.Header().Set("Location", "/v0/users/bob/refs/82eafab14dc84ed3")
+		w.WriteHeader(201)
+	}))
+
+	partial := map[string]interface{}{
+		"address":  map[string]string{"city": "Lima"},
+		"nickname": nil,
+	}
+
+	path, err := c.MergePatch("users", "bob", partial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path.Ref != "82eafab14dc84ed3" {
+		t.Errorf("unexpected ref %q", path.Ref)
+	}
+
+	_, err = c.MergePatchIfUnmodified(NewPath("users", "bob").WithRef("stale"), partial)
+	if !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("expected ErrPreconditionFailed, got %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+// Calls fn with every index from 0 to n-1, making at most concurrency calls
+// at once, and returns once all of them have finished.
+func parallel(n, concurrency int, fn func(i int)) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency && w < n; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				fn(i)
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+}
+
+// Like parallel, except that calls whose indexes share a key are made one
+// after another in index order, by always handing a key to the same worker.
+// Calls for different keys may still be made at once.
+func parallelByKey(n, concurrency int, key func(i int) string, fn func(i int)) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if concurrency > n {
+		concurrency = n
+	}
+
+	queues := make([]chan int, concurrency)
+	var wg sync.WaitGroup
+	for w := range queues {
+		queues[w] = make(chan int)
+		wg.Add(1)
+		go func(queue chan int) {
+			defer wg.Done()
+			for i := range queue {
+				fn(i)
+			}
+		}(queues[w])
+	}
+
+	for i := 0; i < n; i++ {
+		h := fnv.New32a()
+		h.Write([]byte(key(i)))
+		queues[h.Sum32()%uint32(concurrency)] <- i
+	}
+	for _, queue := range queues {
+		close(queue)
+	}
+	wg.Wait()
+}
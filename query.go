@@ -0,0 +1,126 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A Lucene query built up from clauses on fields, for passing to Search by
+// its String. Values are escaped as the clauses are built, so user input can
+// be passed as is.
+//
+//	q := Q("value.name").Match("bob").And(Q("value.age").Range(10, 20))
+//	results, err := c.Search("users", q.String(), 10, 0)
+type Query struct {
+	expr string
+
+	// Whether the query joins clauses with an operator, and so needs
+	// parentheses when it is combined with another.
+	compound bool
+}
+
+// A field of a value, named with dots as in "value.address.city", about to be
+// given a clause. See Q.
+type Field struct {
+	name string
+}
+
+// Start a clause on a field.
+func Q(field string) Field {
+	return Field{name: field}
+}
+
+// Returns a query matching values whose field holds the term.
+func (f Field) Match(term string) Query {
+	return f.clause(escapeQuery(term))
+}
+
+// Returns a query matching values whose field holds the words of text in
+// order.
+func (f Field) Phrase(text string) Query {
+	return f.clause(`"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(text) + `"`)
+}
+
+// Returns a query matching values whose field holds a term starting with
+// prefix.
+func (f Field) Prefix(prefix string) Query {
+	return f.clause(escapeQuery(prefix) + "*")
+}
+
+// Returns a query matching values whose field lies between min and max,
+// inclusive. Either bound may be nil to leave that end of the range open.
+func (f Field) Range(min, max interface{}) Query {
+	return f.clause("[" + rangeBound(min) + " TO " + rangeBound(max) + "]")
+}
+
+// Returns a query matching values that have the field at all.
+func (f Field) Exists() Query {
+	return f.clause("*")
+}
+
+// Returns a query of the field followed by an already escaped value.
+func (f Field) clause(value string) Query {
+	return Query{expr: escapeQuery(f.name) + ":" + value}
+}
+
+// Formats one end of a range.
+func rangeBound(bound interface{}) string {
+	switch b := bound.(type) {
+	case nil:
+		return "*"
+	case string:
+		return escapeQuery(b)
+	default:
+		return escapeQuery(fmt.Sprint(b))
+	}
+}
+
+// Returns a query matching values that match both q and other. The other
+// query may be a Query, a GeoQuery or any other Stringer producing Lucene
+// syntax.
+func (q Query) And(other fmt.Stringer) Query {
+	return q.join("AND", other)
+}
+
+// Returns a query matching values that match either q or other. See And.
+func (q Query) Or(other fmt.Stringer) Query {
+	return q.join("OR", other)
+}
+
+// Returns a query matching values that match q but not other. See And.
+func (q Query) AndNot(other fmt.Stringer) Query {
+	return q.join("AND NOT", other)
+}
+
+// Returns a query joining q and other with a boolean operator.
+func (q Query) join(operator string, other fmt.Stringer) Query {
+	return Query{expr: q.operand() + " " + operator + " " + operand(other), compound: true}
+}
+
+// Returns the query in the Lucene syntax Search expects.
+func (q Query) String() string {
+	return q.expr
+}
+
+// Formats the query for combining with another.
+func (q Query) operand() string {
+	if q.compound {
+		return "(" + q.expr + ")"
+	}
+	return q.expr
+}
+
+// Formats a query for combining with another, in parentheses unless it is
+// known to be a single clause.
+func operand(query fmt.Stringer) string {
+	switch q := query.(type) {
+	case Query:
+		return q.operand()
+	case GeoQuery:
+		return q.String()
+	default:
+		return "(" + q.String() + ")"
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+import (
+	"testing"
+)
+
+func TestQuery(t *testing.T) {
+	tests := []struct {
+		query    Query
+		expected string
+	}{
+		{Q("value.name").Match("bob"), `value.name:bob`},
+		{Q("value.name").Match("C++ (beta)"), `value.name:C\+\+\ \(beta\)`},
+		{Q("value.title").Phrase(`say "hi"`), `value.title:"say \"hi\""`},
+		{Q("value.name").Prefix("bo"), `value.name:bo*`},
+		{Q("value.age").Range(10, 20), `value.age:[10 TO 20]`},
+		{Q("value.age").Range(-5, nil), `value.age:[\-5 TO *]`},
+		{Q("value.email").Exists(), `value.email:*`},
+		{
+			Q("value.name").Match("foo").And(Q("value.age").Range(10, 20)),
+			`value.name:foo AND value.age:[10 TO 20]`,
+		},
+		{
+			Q("value.a").Match("1").Or(Q("value.b").Match("2")).And(Q("value.c").Match("3")),
+			`(value.a:1 OR value.b:2) AND value.c:3`,
+		},
+		{
+			Q("value.a").Match("1").AndNot(Q("value.b").Match("2").Or(Q("value.c").Match("3"))),
+			`value.a:1 AND NOT (value.b:2 OR value.c:3)`,
+		},
+		{
+			Q("value.open").Match("true").And(Near("value.location", 1, 2, "1km")),
+			`value.open:true AND value.location:NEAR:{lat:1 lon:2 dist:1km}`,
+		},
+	}
+
+	for _, test := range tests {
+		if query := test.query.String(); query != test.expected {
+			t.Errorf("expected %s, got %s", test.expected, query)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limit the rate at which the client sends requests to requestsPerSecond,
+// across every goroutine using it and every Client derived from it afterwards
+// with WithAuthToken or WithHeaders, so as to stay under a plan's quota
+// rather than recover from 429 responses. Up to a second's worth of requests
+// may be sent at once after a quiet period; beyond that requests wait their
+// turn. Retries count against the limit. Setting requestsPerSecond to zero,
+// the default, removes the limit.
+//
+// This should be called before the Client is shared between goroutines.
+func (c *Client) SetRateLimit(requestsPerSecond int) {
+	if requestsPerSecond <= 0 {
+		c.limiter = nil
+		return
+	}
+	c.limiter = newRateLimiter(float64(requestsPerSecond))
+}
+
+// A token bucket holding up to a second's worth of requests, refilled at a
+// steady rate.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64) *rateLimiter {
+	return &rateLimiter{rate: rate, tokens: rate, last: time.Now()}
+}
+
+// Waits until a request may be sent, or until ctx is done.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.rate {
+		l.tokens = l.rate
+	}
+	l.last = now
+
+	// Take a token even if there is none yet, so that waiting requests are
+	// sent in turn, each a token's time after the last.
+	l.tokens--
+	delay := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// Give the token back so that abandoned requests don't hold up
+		// the ones waiting behind them.
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+	}))
+	c.SetRateLimit(50)
+
+	start := time.Now()
+	for i := 0; i < 60; i++ {
+		if _, err := c.Exists("users", "bob"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The first 50 requests are sent at once, the other 10 at 20ms intervals.
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("expected the requests to be paced, took %v", elapsed)
+	}
+}
+
+func TestRateLimitCancelledWait(t *testing.T) {
+	l := newRateLimiter(10)
+	l.tokens = 0
+
+	// Each of these would otherwise push the next request back another 100ms.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for i := 0; i < 20; i++ {
+		if err := l.wait(ctx); err != context.Canceled {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	}
+
+	start := time.Now()
+	if err := l.wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("expected cancelled waits to give back their tokens, waited %v", elapsed)
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+// A request made by the client and the response it received, as written by
+// Record and read by Replay.
+type interaction struct {
+	Method         string      `json:"method"`
+	URI            string      `json:"uri"`
+	RequestHeader  http.Header `json:"request_header,omitempty"`
+	RequestBody    string      `json:"request_body,omitempty"`
+	StatusCode     int         `json:"status_code"`
+	ResponseHeader http.Header `json:"response_header,omitempty"`
+	ResponseBody   string      `json:"response_body,omitempty"`
+}
+
+// Write every request the client makes, and the response it receives, to w
+// as a line of JSON, for reproducing problems or for serving later with
+// Replay. The Authorization header is left out, so the auth token is never
+// recorded, but request and response bodies are recorded in full. Responses
+// are read into memory before they are returned, so values are not streamed
+// while recording. The http.Client in use is copied rather than modified.
+//
+// This should be called before the Client is shared between goroutines.
+func (c *Client) Record(w io.Writer) {
+	httpClient := *c.httpClient
+	httpClient.Transport = &recordingTransport{next: transportOf(c.httpClient), w: w}
+	c.httpClient = &httpClient
+}
+
+// Serve every request the client makes from the interactions read from r, as
+// written by Record, rather than from the network. A request is answered by
+// the first interaction not yet used that has the same method and URI, path
+// and query alike, so requests repeated with different results replay in
+// the order they were recorded. A request with no such interaction fails.
+// The http.Client in use is copied rather than modified.
+//
+// This should be called before the Client is shared between goroutines.
+func (c *Client) Replay(r io.Reader) error {
+	cassette := &replayTransport{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 64<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var recorded interaction
+		if err := json.Unmarshal(scanner.Bytes(), &recorded); err != nil {
+			return fmt.Errorf("line %d: %v", line, err)
+		}
+		cassette.interactions = append(cassette.interactions, recorded)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	httpClient := *c.httpClient
+	httpClient.Transport = cassette
+	c.httpClient = &httpClient
+	return nil
+}
+
+// Returns the RoundTripper an http.Client sends its requests through.
+func transportOf(httpClient *http.Client) http.RoundTripper {
+	if httpClient.Transport != nil {
+		return httpClient.Transport
+	}
+	return http.DefaultTransport
+}
+
+// An http.RoundTripper that records each request and response it passes on.
+type recordingTransport struct {
+	next http.RoundTripper
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	recorded := interaction{
+		Method:        req.Method,
+		URI:           req.URL.RequestURI(),
+		RequestHeader: req.Header.Clone(),
+	}
+	recorded.RequestHeader.Del("Authorization")
+
+	if req.Body != nil {
+		body, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		recorded.RequestBody = string(body)
+
+		req = req.Clone(req.Context())
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	recorded.StatusCode = resp.StatusCode
+	recorded.ResponseHeader = resp.Header
+	recorded.ResponseBody = string(body)
+
+	line, err := json.Marshal(recorded)
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, err := t.w.Write(append(line, '\n')); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// An http.RoundTripper that answers requests from recorded interactions.
+type replayTransport struct {
+	mu           sync.Mutex
+	interactions []interaction
+	used         []bool
+}
+
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		io.Copy(ioutil.Discard, req.Body)
+		req.Body.Close()
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.used == nil {
+		t.used = make([]bool, len(t.interactions))
+	}
+
+	uri := req.URL.RequestURI()
+	for i, recorded := range t.interactions {
+		if t.used[i] || recorded.Method != req.Method || recorded.URI != uri {
+			continue
+		}
+		t.used[i] = true
+
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", recorded.StatusCode, http.StatusText(recorded.StatusCode)),
+			StatusCode:    recorded.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        recorded.ResponseHeader,
+			Body:          ioutil.NopCloser(bytes.NewReader([]byte(recorded.ResponseBody))),
+			ContentLength: int64(len(recorded.ResponseBody)),
+			Request:       req,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("no recorded response for %s %s", req.Method, uri)
+}
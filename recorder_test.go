@@ -0,0 +1,64 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PUT" {
+			if body, _ := ioutil.ReadAll(r.Body); string(body) != `{"name":"bob"}`+"\n" {
+				t.Errorf("expected the body to reach the server, got %q", body)
+			}
+			w.Header().Set("Location", "/v0/users/bob/refs/0eb4e5dca3a2ec1b")
+			w.WriteHeader(201)
+			return
+		}
+		w.Header().Set("ETag", `"0eb4e5dca3a2ec1b"`)
+		fmt.Fprint(w, `{"name":"bob"}`)
+	}))
+
+	cassette := new(bytes.Buffer)
+	c.Record(cassette)
+
+	if _, err := c.Put("users", "bob", map[string]string{"name": "bob"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get("users", "bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(cassette.String(), "Basic ") {
+		t.Error("expected the Authorization header to be left out of the recording")
+	}
+	if lines := strings.Count(cassette.String(), "\n"); lines != 2 {
+		t.Fatalf("expected 2 recorded interactions, got %d", lines)
+	}
+
+	replayed := newTestClient(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		t.Errorf("unexpected request to the network: %s %s", req.Method, req.URL)
+		return nil, fmt.Errorf("offline")
+	}))
+	if err := replayed.Replay(cassette); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := replayed.Get("users", "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(result.RawValue) != `{"name":"bob"}` || result.Path.Ref != "0eb4e5dca3a2ec1b" {
+		t.Errorf("unexpected replayed result %+v", result)
+	}
+
+	if _, err := replayed.Get("users", "bob"); err == nil {
+		t.Error("expected an error once the recorded interaction was used up")
+	}
+}
@@ -0,0 +1,21 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+// The header that carries the ID of each request when IDs are generated. See
+// SetRequestIDGenerator.
+const RequestIDHeader = "X-Request-Id"
+
+// Send the ID returned by generate with every request, in the RequestIDHeader
+// header, so that a request can be traced through the logs of both the
+// application and Orchestrate. The ID is reported in the RequestID of an
+// OrchestrateError, at the end of its message, and to the hook set by
+// SetRetryHook, and is kept when the request is retried. A single call can be
+// given an ID of its own with WithHeaders, which replaces the generated one.
+// generate must be safe to call from several goroutines at once. Setting it to
+// nil, the default, stops IDs being sent.
+//
+// This should be called before the Client is shared between goroutines.
+func (c *Client) SetRequestIDGenerator(generate func() string) {
+	c.requestID = generate
+}
@@ -0,0 +1,54 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSetRequestIDGenerator(t *testing.T) {
+	var seen []string
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		seen = append(seen, r.Header.Get(RequestIDHeader))
+		w.WriteHeader(404)
+		fmt.Fprint(w, `{"message":"not found"}`)
+	}))
+
+	var n int32
+	c.SetRequestIDGenerator(func() string {
+		return fmt.Sprintf("req-%d", atomic.AddInt32(&n, 1))
+	})
+
+	var info RetryInfo
+	c.SetRetryHook(func(i RetryInfo) { info = i })
+
+	_, err := c.Get("users", "bob")
+	var oe *OrchestrateError
+	if !errors.As(err, &oe) || oe.RequestID != "req-1" || !strings.Contains(err.Error(), "req-1") {
+		t.Errorf("expected the error to carry the request ID, got %v", err)
+	}
+	if info.RequestID != "req-1" {
+		t.Errorf("expected the retry hook to see the request ID, got %q", info.RequestID)
+	}
+
+	c.WithHeaders(map[string]string{RequestIDHeader: "mine"}).Get("users", "bob")
+	if fmt.Sprint(seen) != "[req-1 mine]" {
+		t.Errorf("unexpected request IDs %v", seen)
+	}
+}
+
+func TestRequestIDTransportError(t *testing.T) {
+	c := newTestClient(roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	}))
+	c.SetRequestIDGenerator(func() string { return "req-1" })
+
+	if _, err := c.Get("users", "bob"); err == nil || !strings.Contains(err.Error(), "request req-1") {
+		t.Errorf("expected the request ID in the error, got %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+// The behavior shared by every kind of result holding a stored JSON value:
+// *KVResult, *Event, *GraphResult, *SearchResult and *EventSearchResult. This
+// allows any query's results to be processed generically.
+type Result interface {
+	// Marshall the JSON value into the provided object.
+	Value(value interface{}) error
+
+	// Returns a copy of the raw JSON value.
+	Raw() []byte
+}
+
+var (
+	_ Result = (*KVResult)(nil)
+	_ Result = (*Event)(nil)
+	_ Result = (*GraphResult)(nil)
+	_ Result = (*SearchResult)(nil)
+	_ Result = (*EventSearchResult)(nil)
+)
+
+// Returns the key/value results as Results. Each refers to an element of
+// r.Results rather than a copy.
+func (r *KVResults) Items() []Result {
+	items := make([]Result, len(r.Results))
+	for i := range r.Results {
+		items[i] = &r.Results[i]
+	}
+	return items
+}
+
+// Returns the events as Results. Each refers to an element of r.Results
+// rather than a copy.
+func (r *EventResults) Items() []Result {
+	items := make([]Result, len(r.Results))
+	for i := range r.Results {
+		items[i] = &r.Results[i]
+	}
+	return items
+}
+
+// Returns the graph results as Results. Each refers to an element of
+// r.Results rather than a copy.
+func (r *GraphResults) Items() []Result {
+	items := make([]Result, len(r.Results))
+	for i := range r.Results {
+		items[i] = &r.Results[i]
+	}
+	return items
+}
+
+// Returns the search results as Results. Each refers to an element of
+// r.Results rather than a copy.
+func (r *SearchResults) Items() []Result {
+	items := make([]Result, len(r.Results))
+	for i := range r.Results {
+		items[i] = &r.Results[i]
+	}
+	return items
+}
@@ -0,0 +1,33 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestItems(t *testing.T) {
+	value := json.RawMessage(`{"n":1}`)
+	collections := []interface{ Items() []Result }{
+		&KVResults{Results: []KVResult{{RawValue: value}}},
+		&EventResults{Results: []Event{{RawValue: value}}},
+		&GraphResults{Results: []GraphResult{{RawValue: value}}},
+		&SearchResults{Results: []SearchResult{{RawValue: value}}},
+	}
+
+	for _, collection := range collections {
+		items := collection.Items()
+		if len(items) != 1 {
+			t.Fatalf("%T: expected 1 item, got %d", collection, len(items))
+		}
+
+		var decoded struct{ N int }
+		if err := items[0].Value(&decoded); err != nil || decoded.N != 1 {
+			t.Errorf("%T: unexpected value %+v, %v", collection, decoded, err)
+		}
+		if string(items[0].Raw()) != string(value) {
+			t.Errorf("%T: unexpected raw value %q", collection, items[0].Raw())
+		}
+	}
+}
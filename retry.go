@@ -0,0 +1,102 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Configure how many times a failed request will be retried, and how long to
+// wait before the first retry. The wait doubles with each subsequent attempt.
+// Only safe requests (GET and HEAD) are retried, and only when they fail with
+// a network error (a refused or reset connection, a DNS failure, a timeout)
+// or with a status indicating the service is temporarily unable to respond
+// (429, 502, 503 or 504). Any other failure is returned immediately. Setting
+// max to zero, the default, disables retries.
+//
+// This should be called before the Client is shared between goroutines.
+func (c *Client) SetRetries(max int, backoff time.Duration) {
+	c.maxRetries = max
+	c.retryBackoff = backoff
+}
+
+// How a request was attempted, as reported to the hook set by SetRetryHook.
+type RetryInfo struct {
+	// The method and path of the request.
+	Method string
+	Path   string
+
+	// The ID the request was sent with, if any. See SetRequestIDGenerator.
+	RequestID string
+
+	// The number of times the request was sent, one more than the number of
+	// retries.
+	Attempts int
+
+	// The status of the last response received, or zero if none was.
+	LastStatus int
+
+	// The total time spent waiting between attempts.
+	TotalDelay time.Duration
+
+	// The error the last attempt failed with, if it failed without a
+	// response. A request that received an error status has a nil Err,
+	// but a LastStatus describing it.
+	Err error
+}
+
+// Call hook once every request has finished being attempted, whether it
+// succeeded, failed or was retried, so that the rate of retries can be
+// monitored. Requests that needed no retry report a single attempt. The hook
+// is called from the goroutine making the request and must be safe to call
+// from several at once. Setting hook to nil, the default, removes it.
+//
+// This should be called before the Client is shared between goroutines.
+func (c *Client) SetRetryHook(hook func(RetryInfo)) {
+	c.retryHook = hook
+}
+
+// Reports whether a request that produced the given response or error is
+// worth attempting again.
+func shouldRetry(req *http.Request, resp *http.Response, err error) bool {
+	if req.Method != "GET" && req.Method != "HEAD" {
+		return false
+	}
+
+	if err != nil {
+		return isNetworkError(err)
+	}
+
+	return isRetryableStatus(resp.StatusCode)
+}
+
+// Reports whether an error returned by an http.Client is caused by the
+// network, rather than by a request that can never succeed.
+func isNetworkError(err error) bool {
+	// Every error from http.Client.Do is wrapped in a *url.Error, which itself
+	// satisfies net.Error, so examine what it wraps instead.
+	if ue, ok := err.(*url.Error); ok {
+		err = ue.Err
+	}
+
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var ne net.Error
+	return errors.As(err, &ne)
+}
+
+// Reports whether a response status indicates a transient condition.
+func isRetryableStatus(status int) bool {
+	switch status {
+	case 429, 502, 503, 504:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// Returns a RoundTripper that fails with err the first failures times it is
+// called and succeeds afterwards, counting every call in calls.
+func flakyTransport(failures int, err error, calls *int) http.RoundTripper {
+	ok := serve(func(w http.ResponseWriter, r *http.Request) {})
+	return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		*calls++
+		if *calls <= failures {
+			return nil, err
+		}
+		return ok.RoundTrip(req)
+	})
+}
+
+func TestRetryNetworkError(t *testing.T) {
+	reset := &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+
+	calls := 0
+	c := newTestClient(flakyTransport(2, reset, &calls))
+	c.SetRetries(2, 0)
+
+	if exists, err := c.Exists("users", "bob"); err != nil || !exists {
+		t.Fatalf("Exists() = %v, %v; expected true, nil", exists, err)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 attempts, got %d", calls)
+	}
+}
+
+func TestRetryLimit(t *testing.T) {
+	timeout := &net.DNSError{Err: "timeout", IsTimeout: true}
+
+	calls := 0
+	c := newTestClient(flakyTransport(5, timeout, &calls))
+	c.SetRetries(2, 0)
+
+	if _, err := c.Exists("users", "bob"); err == nil {
+		t.Fatal("expected an error once retries were exhausted")
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 attempts, got %d", calls)
+	}
+}
+
+func TestRetrySkipsUnsafeMethods(t *testing.T) {
+	reset := &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+
+	calls := 0
+	c := newTestClient(flakyTransport(1, reset, &calls))
+	c.SetRetries(2, 0)
+
+	if _, err := c.PutRaw("users", "bob", strings.NewReader("{}")); err == nil {
+		t.Fatal("expected the PUT to fail without being retried")
+	}
+	if calls != 1 {
+		t.Errorf("expected 1 attempt, got %d", calls)
+	}
+}
+
+func TestRetrySkipsPermanentErrors(t *testing.T) {
+	calls := 0
+	c := newTestClient(flakyTransport(1, errors.New("malformed request"), &calls))
+	c.SetRetries(2, 0)
+
+	if _, err := c.Exists("users", "bob"); err == nil {
+		t.Fatal("expected the request to fail without being retried")
+	}
+	if calls != 1 {
+		t.Errorf("expected 1 attempt, got %d", calls)
+	}
+}
+
+func TestRetryStatus(t *testing.T) {
+	calls := 0
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(503)
+		}
+	}))
+	c.SetRetries(1, 0)
+
+	if exists, err := c.Exists("users", "bob"); err != nil || !exists {
+		t.Fatalf("Exists() = %v, %v; expected true, nil", exists, err)
+	}
+	if calls != 2 {
+		t.Errorf("expected 2 attempts, got %d", calls)
+	}
+}
+
+func TestRetryHook(t *testing.T) {
+	reset := &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+
+	calls := 0
+	c := newTestClient(flakyTransport(2, reset, &calls))
+	c.SetRetries(2, time.Millisecond)
+
+	var reported []RetryInfo
+	c.SetRetryHook(func(info RetryInfo) {
+		reported = append(reported, info)
+	})
+
+	if _, err := c.Exists("users", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Exists("users", "bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reported) != 2 {
+		t.Fatalf("expected the hook to be called for each request, got %d calls", len(reported))
+	}
+
+	retried := reported[0]
+	if retried.Method != "HEAD" || retried.Path != "/v0/users/bob" || retried.Attempts != 3 ||
+		retried.LastStatus != 200 || retried.TotalDelay != 3*time.Millisecond || retried.Err != nil {
+		t.Errorf("unexpected retry info %+v", retried)
+	}
+	if reported[1].Attempts != 1 || reported[1].TotalDelay != 0 {
+		t.Errorf("expected a single attempt without delay, got %+v", reported[1])
+	}
+}
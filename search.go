@@ -3,9 +3,15 @@
 package gorc
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"net/url"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
+	"unicode"
 )
 
 // Holds results returned from a Search query.
@@ -13,8 +19,11 @@ type SearchResults struct {
 	Count      uint64         `json:"count"`
 	TotalCount uint64         `json:"total_count"`
 	Results    []SearchResult `json:"results"`
-	Next       string         `json:"next,omitempty"`
-	Prev       string         `json:"prev,omitempty"`
+	Next       Cursor         `json:"next,omitempty"`
+	Prev       Cursor         `json:"prev,omitempty"`
+
+	// The aggregates requested with SearchAggregate, if any.
+	Aggregates Aggregates `json:"aggregates"`
 }
 
 // An individual search result.
@@ -34,19 +43,283 @@ func (c *Client) Search(collection, query string, limit, offset int) (*SearchRes
 		"offset": []string{strconv.Itoa(offset)},
 	}
 
-	trailingUri := collection + "?" + queryVariables.Encode()
+	trailingUri := buildURI(collection) + "?" + queryVariables.Encode()
+
+	return c.doSearch(trailingUri)
+}
+
+// Search a collection as Search does, except that results are ordered by the
+// given sort expression rather than by score. The expression names one or
+// more fields, each followed by :asc or :desc and separated by commas, as in
+// "value.last_name:asc,value.first_name:asc". Fields of the path, such as the
+// @path.reftime of the latest ref, can be sorted on too.
+func (c *Client) SearchSorted(collection, query, sort string, limit, offset int) (*SearchResults, error) {
+	queryVariables := url.Values{
+		"query":  []string{query},
+		"sort":   []string{sort},
+		"limit":  []string{strconv.Itoa(limit)},
+		"offset": []string{strconv.Itoa(offset)},
+	}
+
+	trailingUri := buildURI(collection) + "?" + queryVariables.Encode()
+
+	return c.doSearch(trailingUri)
+}
+
+// Search a collection as Search does, except that only the given fields of
+// each value are returned, leaving the rest absent from RawValue. Nested
+// fields are named with dots, as in "address.city". The fields are selected
+// by Orchestrate, so the values omitted are never transferred.
+func (c *Client) SearchWithFields(collection, query string, fields []string, limit, offset int) (*SearchResults, error) {
+	withFields := make([]string, len(fields))
+	for i, field := range fields {
+		withFields[i] = "value." + field
+	}
+
+	queryVariables := url.Values{
+		"query":       []string{query},
+		"with_fields": []string{strings.Join(withFields, ",")},
+		"limit":       []string{strconv.Itoa(limit)},
+		"offset":      []string{strconv.Itoa(offset)},
+	}
+
+	trailingUri := buildURI(collection) + "?" + queryVariables.Encode()
 
 	return c.doSearch(trailingUri)
 }
 
-// Get the page of search results that follow that provided set.
+// Search a collection for values whose field starts with prefix, ranked by
+// score, as needed for typeahead. Characters that are special to the Lucene
+// query syntax are escaped in both the field and the prefix, so user input
+// can be passed as is.
+func (c *Client) SearchPrefix(collection, field, prefix string, limit int) (*SearchResults, error) {
+	query := escapeQuery(field) + ":" + escapeQuery(prefix) + "*"
+
+	return c.Search(collection, query, limit, 0)
+}
+
+// Escapes the characters that are special to the Lucene query syntax, along
+// with whitespace, so that the text is matched literally.
+func escapeQuery(text string) string {
+	var escaped strings.Builder
+	for _, r := range text {
+		if strings.ContainsRune(`+-&|!(){}[]^"~*?:\/`, r) || unicode.IsSpace(r) {
+			escaped.WriteByte('\\')
+		}
+		escaped.WriteRune(r)
+	}
+	return escaped.String()
+}
+
+// Get at most limit of the items in a collection that have been written since
+// the given time, oldest first, for incrementally syncing a copy of the
+// collection. This is a search over the time of each item's latest ref, so
+// it reflects the search index, which may lag behind very recent writes, and
+// it can not report deletes. Further pages can be read with ListGetNext or
+// Follow.
+func (c *Client) ListModifiedSince(collection string, since time.Time, limit int) (*KVResults, error) {
+	queryVariables := url.Values{
+		"query": []string{"@path.reftime:[" + strconv.FormatInt(millis(since), 10) + " TO *]"},
+		"sort":  []string{"@path.reftime:asc"},
+		"limit": []string{strconv.Itoa(limit)},
+	}
+
+	trailingUri := buildURI(collection) + "?" + queryVariables.Encode()
+
+	return c.doList(trailingUri)
+}
+
+// The number of values SearchAndFetch reads at once.
+const fetchConcurrency = 8
+
+// Search a collection as Search does, then read the current value of every
+// match with MultiGet. The search transfers only the paths of the matches, so
+// each value is sent once, and reflects the latest ref of its key rather than
+// the copy held by the search index, which may lag behind writes. The results
+// are returned in the order of their search score, best first. Keys deleted
+// since they were indexed are left out.
+func (c *Client) SearchAndFetch(collection, query string, limit int) ([]KVResult, error) {
+	queryVariables := url.Values{
+		"query":       []string{query},
+		"with_fields": []string{"path.key"},
+		"limit":       []string{strconv.Itoa(limit)},
+	}
+
+	hits, err := c.doSearch(buildURI(collection) + "?" + queryVariables.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, len(hits.Results))
+	for i, hit := range hits.Results {
+		keys[i] = hit.Path.Key
+	}
+
+	results, err := c.MultiGet(collection, keys, fetchConcurrency)
+	var merr *MultiGetError
+	if errors.As(err, &merr) {
+		for _, err := range merr.Errors {
+			if err != nil && !errors.Is(err, ErrNotFound) {
+				return nil, err
+			}
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	fetched := make([]KVResult, 0, len(results))
+	for _, result := range results {
+		if result != nil {
+			fetched = append(fetched, *result)
+		}
+	}
+
+	return fetched, nil
+}
+
+// The number of search results DistinctValues reads per request, the most
+// Orchestrate returns at once.
+const distinctPageSize = 100
+
+// Get up to limit distinct values of a field across a collection, sorted, for
+// building filters and facets. The field is named with dots separating nested
+// objects, as in "address.city"; numbers and booleans are returned in their
+// JSON form and every element of an array field counts as a value. Values
+// that are missing, null or objects are skipped.
+//
+// Orchestrate offers no terms aggregate, so this is computed by the client,
+// scanning the whole collection through search pages until limit values have
+// been seen. It costs a request for every hundred items in the worst case and,
+// since the search index is updated shortly after writes, may miss the most
+// recent ones.
+func (c *Client) DistinctValues(collection, field string, limit int) ([]string, error) {
+	results, err := c.Search(collection, "*", distinctPageSize, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	for len(seen) < limit {
+		for _, result := range results.Results {
+			values, err := fieldValues(result.RawValue, field)
+			if err != nil {
+				return nil, err
+			}
+			for _, value := range values {
+				if len(seen) < limit {
+					seen[value] = true
+				}
+			}
+		}
+
+		if !results.HasNext() {
+			break
+		}
+		if results, err = c.SearchGetNext(results); err != nil {
+			return nil, err
+		}
+	}
+
+	distinct := make([]string, 0, len(seen))
+	for value := range seen {
+		distinct = append(distinct, value)
+	}
+	sort.Strings(distinct)
+
+	return distinct, nil
+}
+
+// Get the number of items in a collection, as counted by the search index.
+// Since the index is updated shortly after writes, the most recent ones may
+// not be counted yet.
+func (c *Client) CollectionSize(collection string) (uint64, error) {
+	results, err := c.Search(collection, "*", 1, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	return results.TotalCount, nil
+}
+
+// Estimate how many bytes the values in a collection take up. Orchestrate does
+// not report storage use, so this is only an estimate: the average size of up
+// to sampleSize values read from the search index, multiplied by the size of
+// the collection as CollectionSize counts it. It counts the JSON encoding of
+// the values, not the keys, refs or any overhead of storing them, and is only
+// as good as the sample is representative of the whole collection.
+func (c *Client) EstimateCollectionBytes(collection string, sampleSize int) (uint64, error) {
+	results, err := c.Search(collection, "*", sampleSize, 0)
+	if err != nil {
+		return 0, err
+	}
+	if len(results.Results) == 0 {
+		return 0, nil
+	}
+
+	var sampled uint64
+	for _, result := range results.Results {
+		sampled += uint64(result.Size())
+	}
+
+	return sampled * results.TotalCount / uint64(len(results.Results)), nil
+}
+
+// Extracts the scalar values of a dotted field from a raw JSON value.
+func fieldValues(raw json.RawMessage, field string) ([]string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	for _, name := range strings.Split(field, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, nil
+		}
+		value = object[name]
+	}
+
+	elements, ok := value.([]interface{})
+	if !ok {
+		elements = []interface{}{value}
+	}
+
+	var values []string
+	for _, element := range elements {
+		switch v := element.(type) {
+		case string:
+			values = append(values, v)
+		case json.Number:
+			values = append(values, v.String())
+		case bool:
+			values = append(values, strconv.FormatBool(v))
+		}
+	}
+	return values, nil
+}
+
+// Get the page of search results that follow that provided set, by following
+// the next link returned with them. Orchestrate does not offer a search-after
+// cursor; the next link encodes an offset, so very deep pages remain as costly
+// to fetch as they are with Search.
 func (c *Client) SearchGetNext(results *SearchResults) (*SearchResults, error) {
-	return c.doSearch(results.Next[4:])
+	next := new(SearchResults)
+	if err := c.Follow(results.Next, next); err != nil {
+		return nil, err
+	}
+	return next, nil
 }
 
 // Get the page of search results that precede that provided set.
 func (c *Client) SearchGetPrev(results *SearchResults) (*SearchResults, error) {
-	return c.doSearch(results.Prev[4:])
+	prev := new(SearchResults)
+	if err := c.Follow(results.Prev, prev); err != nil {
+		return nil, err
+	}
+	return prev, nil
 }
 
 // Execute a search request.
@@ -63,10 +336,9 @@ func (c *Client) doSearch(trailingUri string) (*SearchResults, error) {
 		return nil, newError(resp)
 	}
 
-	decoder := json.NewDecoder(resp.Body)
 	result := new(SearchResults)
-	if err := decoder.Decode(result); err != nil {
-		return result, err
+	if err := decodeResponse(resp, result, "search results"); err != nil {
+		return nil, err
 	}
 
 	return result, nil
@@ -86,3 +358,18 @@ func (r *SearchResults) HasPrev() bool {
 func (r *SearchResult) Value(value interface{}) error {
 	return json.Unmarshal(r.RawValue, value)
 }
+
+// Returns a copy of the raw JSON value of a SearchResult.
+func (r *SearchResult) Raw() []byte {
+	return append([]byte(nil), r.RawValue...)
+}
+
+// Returns the size in bytes of the raw JSON value of a SearchResult.
+func (r *SearchResult) Size() int {
+	return len(r.RawValue)
+}
+
+// Reports the top level JSON kind of the value of a SearchResult, as ValueKind does.
+func (r *SearchResult) Kind() (string, error) {
+	return ValueKind(r.RawValue)
+}
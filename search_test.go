@@ -3,12 +3,60 @@
 package gorc
 
 import (
+	"fmt"
+	"net/http"
+	"sync"
 	"testing"
 	"testing/quick"
+	"time"
 )
 
+func TestSearch(t *testing.T) {
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v0/users" || r.URL.RawQuery != "limit=10&offset=20&query=name%3Abob" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		fmt.Fprint(w, `{"count":1,"total_count":21,"results":[`+
+			`{"path":{"collection":"users","key":"bob","ref":"r1"},"score":1.5,"value":{"name":"bob"}}]}`)
+	}))
+
+	results, err := c.Search("users", "name:bob", 10, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results.Count != 1 || results.TotalCount != 21 || len(results.Results) != 1 {
+		t.Fatalf("unexpected results %+v", results)
+	}
+
+	result := results.Results[0]
+	value := make(map[string]string)
+	if err := result.Value(&value); err != nil {
+		t.Fatal(err)
+	}
+	if result.Path.Key != "bob" || result.Path.Ref != "r1" || result.Score != 1.5 || value["name"] != "bob" {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
+
+func TestSearchSorted(t *testing.T) {
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("query") != "*" || query.Get("sort") != "value.last:asc,value.first:desc" || query.Get("limit") != "5" {
+			t.Errorf("unexpected query %s", r.URL.RawQuery)
+		}
+		fmt.Fprint(w, `{"count":0,"results":[]}`)
+	}))
+
+	if _, err := c.SearchSorted("users", "*", "value.last:asc,value.first:desc", 5, 0); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestSearchHasNext(t *testing.T) {
 	f := func(results *SearchResults) bool {
+		if results == nil {
+			return true
+		}
 		return !(results.Next == "" && results.HasNext())
 	}
 
@@ -19,6 +67,9 @@ func TestSearchHasNext(t *testing.T) {
 
 func TestSearchHasPrev(t *testing.T) {
 	f := func(results *SearchResults) bool {
+		if results == nil {
+			return true
+		}
 		return !(results.Prev == "" && results.HasPrev())
 	}
 
@@ -26,3 +77,228 @@ func TestSearchHasPrev(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestSearchGetNext(t *testing.T) {
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("offset") == "10" {
+			fmt.Fprint(w, `{"count":1,"total_count":11,"results":[{"path":{"collection":"users","key":"k"}}],"prev":"/v0/users?query=bob&limit=10&offset=0"}`)
+			return
+		}
+		fmt.Fprint(w, `{"count":10,"total_count":11,"results":[],"next":"/v0/users?query=bob&limit=10&offset=10"}`)
+	}))
+
+	results, err := c.Search("users", "bob", 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !results.HasNext() {
+		t.Fatal("expected a next page")
+	}
+
+	results, err = c.SearchGetNext(results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results.HasNext() || !results.HasPrev() || len(results.Results) != 1 {
+		t.Errorf("unexpected second page %+v", results)
+	}
+
+	results, err = c.SearchGetPrev(results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !results.HasNext() || results.HasPrev() || len(results.Results) != 0 {
+		t.Errorf("expected to return to the first page, got %+v", results)
+	}
+}
+
+func TestSearchAndFetch(t *testing.T) {
+	hits := `{"count":3,"total_count":3,"results":[` +
+		`{"path":{"collection":"users","key":"carol"},"score":3},` +
+		`{"path":{"collection":"users","key":"gone"},"score":2},` +
+		`{"path":{"collection":"users","key":"alice"},"score":1}]}`
+	notFound := `{"message":"not found"}`
+
+	var mu sync.Mutex
+	var requests, sent int
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		var body string
+		switch r.URL.Path {
+		case "/v0/users":
+			if fields := r.URL.Query().Get("with_fields"); fields != "path.key" {
+				t.Errorf("expected the search to leave out the values, got with_fields %q", fields)
+			}
+			body = hits
+		case "/v0/users/carol", "/v0/users/alice":
+			key := r.URL.Path[len("/v0/users/"):]
+			w.Header().Set("Content-Location", "/v0/users/"+key+"/refs/1")
+			body = fmt.Sprintf(`{"name":%q}`, key)
+		default:
+			w.WriteHeader(404)
+			body = notFound
+		}
+		mu.Lock()
+		requests++
+		sent += len(body)
+		mu.Unlock()
+		fmt.Fprint(w, body)
+	}))
+
+	results, err := c.SearchAndFetch("users", "*", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Path.Key != "carol" || results[1].Path.Key != "alice" {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	if string(results[1].RawValue) != `{"name":"alice"}` {
+		t.Errorf("unexpected value %q", results[1].RawValue)
+	}
+	if requests != 4 {
+		t.Errorf("expected a search and a read per match, made %d requests", requests)
+	}
+	if expected := len(hits) + len(`{"name":"carol"}`) + len(`{"name":"alice"}`) + len(notFound); sent != expected {
+		t.Errorf("expected each value to be sent once, sent %d bytes", sent)
+	}
+}
+
+func TestDistinctValues(t *testing.T) {
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("offset") == "100" {
+			fmt.Fprint(w, `{"count":2,"total_count":102,"results":[`+
+				`{"path":{"collection":"users","key":"d"},"value":{"address":{"city":"Paris"},"tags":[]}},`+
+				`{"path":{"collection":"users","key":"e"},"value":{"address":null}}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"count":3,"total_count":102,"results":[`+
+			`{"path":{"collection":"users","key":"a"},"value":{"address":{"city":"Oslo"}}},`+
+			`{"path":{"collection":"users","key":"b"},"value":{"address":{"city":"Lima"}}},`+
+			`{"path":{"collection":"users","key":"c"},"value":{"address":{"city":"Oslo"}}}],`+
+			`"next":"/v0/users?query=*&limit=100&offset=100"}`)
+	}))
+
+	values, err := c.DistinctValues("users", "address.city", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(values) != "[Lima Oslo Paris]" {
+		t.Errorf("unexpected distinct values %q", values)
+	}
+
+	values, err = c.DistinctValues("users", "address.city", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(values) != "[Lima Oslo]" {
+		t.Errorf("expected the scan to stop at the limit, got %q", values)
+	}
+}
+
+func TestFieldValues(t *testing.T) {
+	values, err := fieldValues([]byte(`{"tags":["a",2,true,null,{"x":1}],"n":1152921504606846977}`), "tags")
+	if err != nil || fmt.Sprint(values) != "[a 2 true]" {
+		t.Errorf("fieldValues(tags) = %q, %v", values, err)
+	}
+
+	values, err = fieldValues([]byte(`{"n":1152921504606846977}`), "n")
+	if err != nil || fmt.Sprint(values) != "[1152921504606846977]" {
+		t.Errorf("fieldValues(n) = %q, %v", values, err)
+	}
+
+	values, err = fieldValues([]byte(`{"n":"x"}`), "n.m")
+	if err != nil || len(values) != 0 {
+		t.Errorf("fieldValues(n.m) = %q, %v", values, err)
+	}
+}
+
+func TestEscapeQuery(t *testing.T) {
+	tests := map[string]string{
+		"bob":         "bob",
+		"value.name":  "value.name",
+		`a+b (c) "d"`: `a\+b\ \(c\)\ \"d\"`,
+		`x:y*z?\`:     `x\:y\*z\?\\`,
+		"a/b&&c||!d":  `a\/b\&\&c\|\|\!d`,
+	}
+
+	for text, expected := range tests {
+		if actual := escapeQuery(text); actual != expected {
+			t.Errorf("escapeQuery(%q) = %q, expected %q", text, actual, expected)
+		}
+	}
+}
+
+func TestSearchPrefix(t *testing.T) {
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		if query := r.URL.Query().Get("query"); query != `value.name:o'b\ r*` {
+			t.Errorf("unexpected query %q", query)
+		}
+		if limit := r.URL.Query().Get("limit"); limit != "5" {
+			t.Errorf("expected a limit of 5, got %q", limit)
+		}
+		fmt.Fprint(w, `{"count":0,"total_count":0,"results":[]}`)
+	}))
+
+	if _, err := c.SearchPrefix("users", "value.name", "o'b r", 5); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestListModifiedSince(t *testing.T) {
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if q := query.Get("query"); q != "@path.reftime:[1398286800000 TO *]" {
+			t.Errorf("unexpected query %q", q)
+		}
+		if sort := query.Get("sort"); sort != "@path.reftime:asc" {
+			t.Errorf("unexpected sort %q", sort)
+		}
+		fmt.Fprint(w, `{"count":1,"total_count":1,"results":[{"path":{"collection":"users","key":"bob","ref":"a"},"score":1,"value":{"name":"bob"}}]}`)
+	}))
+
+	results, err := c.ListModifiedSince("users", time.Date(2014, 4, 23, 21, 0, 0, 0, time.UTC), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results.Results) != 1 || results.Results[0].Path.Key != "bob" || string(results.Results[0].RawValue) != `{"name":"bob"}` {
+		t.Errorf("unexpected results %+v", results)
+	}
+}
+
+func TestSearchWithFields(t *testing.T) {
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		if fields := r.URL.Query().Get("with_fields"); fields != "value.name,value.address.city" {
+			t.Errorf("unexpected with_fields %q", fields)
+		}
+		fmt.Fprint(w, `{"count":1,"total_count":1,"results":[{"path":{"collection":"users","key":"bob"},"value":{"name":"bob"}}]}`)
+	}))
+
+	results, err := c.SearchWithFields("users", "bob", []string{"name", "address.city"}, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results.Results) != 1 || string(results.Results[0].RawValue) != `{"name":"bob"}` {
+		t.Errorf("unexpected results %+v", results)
+	}
+}
+
+func TestEstimateCollectionBytes(t *testing.T) {
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("query") != "*" {
+			t.Errorf("unexpected query %q", r.URL.RawQuery)
+		}
+		fmt.Fprint(w, `{"count":2,"total_count":50,"results":[`+
+			`{"path":{"collection":"users","key":"a"},"value":{"n":1}},`+
+			`{"path":{"collection":"users","key":"b"},"value":{"n":100}}]}`)
+	}))
+
+	size, err := c.CollectionSize("users")
+	if err != nil || size != 50 {
+		t.Errorf("expected 50 items, got %d, %v", size, err)
+	}
+
+	// The sampled values average 8 bytes.
+	bytes, err := c.EstimateCollectionBytes("users", 2)
+	if err != nil || bytes != 400 {
+		t.Errorf("expected an estimate of 400 bytes, got %d, %v", bytes, err)
+	}
+}
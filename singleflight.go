@@ -0,0 +1,106 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// Make concurrent calls to Get and GetPath for the same collection-key pair
+// share a single request, every caller receiving its own copy of the result,
+// or the same error. This collapses a stampede of reads of a popular key into
+// one. Reads of a specific ref are never shared, nor are reads made with
+// different auth tokens, and reads by a Client with headers from WithHeaders
+// or an authenticator from SetAuthenticator are never shared at all, since
+// their responses may differ. A caller that joins a request already in
+// flight stops waiting when its own context, set by WithContext, is done. If
+// the shared request fails because the context of the caller that started it
+// ended, the error is not passed on; the callers that joined it try again
+// with their own contexts. Calls are shared across every Client derived from
+// this one afterwards with WithAuthToken, WithHeaders or WithContext.
+//
+// This should be called before the Client is shared between goroutines.
+func (c *Client) SetSingleFlight(enabled bool) {
+	if !enabled {
+		c.flights = nil
+		return
+	}
+	c.flights = &flightGroup{calls: make(map[flightKey]*flightCall)}
+}
+
+// Identifies reads that may share a request.
+type flightKey struct {
+	authToken string
+	path      Path
+}
+
+// A read in flight, and its outcome once it has finished.
+type flightCall struct {
+	done   chan struct{}
+	result *KVResult
+	err    error
+}
+
+// The reads currently in flight.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[flightKey]*flightCall
+}
+
+// Calls fn, unless a call for the same key is already in flight, in which
+// case its outcome is awaited and shared instead. A caller waiting for
+// another's call gives up once ctx is done, and makes the call again if the
+// one it waited for ended with a context error, which belongs to the caller
+// that made it.
+func (g *flightGroup) do(ctx context.Context, key flightKey, fn func() (*KVResult, error)) (*KVResult, error) {
+	for {
+		g.mu.Lock()
+		call, ok := g.calls[key]
+		if !ok {
+			break
+		}
+		g.mu.Unlock()
+
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		if !isContextError(call.err) {
+			return call.outcome()
+		}
+	}
+
+	call := &flightCall{done: make(chan struct{})}
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	call.result, call.err = fn()
+
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+	close(call.done)
+
+	return call.outcome()
+}
+
+// Reports whether err comes from a context that was cancelled or whose
+// deadline passed.
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// Returns a copy of the call's result, so that no caller can change another's.
+func (call *flightCall) outcome() (*KVResult, error) {
+	if call.err != nil {
+		return nil, call.err
+	}
+
+	result := *call.result
+	result.RawValue = call.result.Raw()
+	return &result, nil
+}
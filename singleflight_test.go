@@ -0,0 +1,177 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSingleFlight(t *testing.T) {
+	var requests int32
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		// Hold the request long enough for the other readers to join it.
+		time.Sleep(50 * time.Millisecond)
+		w.Header().Set("ETag", `"0eb4e5dca3a2ec1b"`)
+		fmt.Fprint(w, `{"name":"bob"}`)
+	}))
+	c.SetSingleFlight(true)
+	other := c.WithAuthToken("other")
+
+	var wg sync.WaitGroup
+	results := make([]*KVResult, 10)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			result, err := c.Get("users", "bob")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			results[i] = result
+		}(i)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if _, err := other.Get("users", "bob"); err != nil {
+			t.Error(err)
+		}
+	}()
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("expected one request per auth token, got %d", n)
+	}
+
+	results[0].RawValue[0] = 'X'
+	for _, result := range results[1:] {
+		if result == nil || string(result.RawValue) != `{"name":"bob"}` || result.Path.Ref != "0eb4e5dca3a2ec1b" {
+			t.Fatalf("expected every reader to receive its own copy, got %+v", result)
+		}
+	}
+}
+
+func TestSingleFlightNotSharedWithHeaders(t *testing.T) {
+	var requests int32
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		time.Sleep(50 * time.Millisecond)
+		w.Header().Set("ETag", `"0eb4e5dca3a2ec1b"`)
+		fmt.Fprintf(w, `{"feature":%q}`, r.Header.Get("X-Feature"))
+	}))
+	c.SetSingleFlight(true)
+	experimental := c.WithHeaders(map[string]string{"X-Feature": "on"})
+
+	var wg sync.WaitGroup
+	var plain, featured *KVResult
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		plain, _ = c.Get("users", "bob")
+	}()
+	go func() {
+		defer wg.Done()
+		featured, _ = experimental.Get("users", "bob")
+	}()
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("expected reads with extra headers not to be shared, got %d requests", n)
+	}
+	if plain == nil || featured == nil || string(featured.RawValue) != `{"feature":"on"}` {
+		t.Errorf("unexpected results %+v and %+v", plain, featured)
+	}
+}
+
+// Returns a client whose first request blocks until its context is done or
+// release is closed, and whose later requests succeed at once.
+func newBlockingClient(started, release chan struct{}, requests *int32) *Client {
+	ok := serve(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"0eb4e5dca3a2ec1b"`)
+		fmt.Fprint(w, `{"name":"bob"}`)
+	})
+	c := newTestClient(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if atomic.AddInt32(requests, 1) == 1 {
+			close(started)
+			select {
+			case <-req.Context().Done():
+				return nil, req.Context().Err()
+			case <-release:
+			}
+		}
+		return ok.RoundTrip(req)
+	}))
+	c.SetSingleFlight(true)
+	return c
+}
+
+func TestSingleFlightFollowerContext(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	var requests int32
+	c := newBlockingClient(started, release, &requests)
+
+	leader := make(chan error)
+	go func() {
+		_, err := c.Get("users", "bob")
+		leader <- err
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := c.WithContext(ctx).Get("users", "bob"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the follower to stop at its own deadline, got %v", err)
+	}
+
+	close(release)
+	if err := <-leader; err != nil {
+		t.Errorf("expected the leader to succeed, got %v", err)
+	}
+}
+
+func TestSingleFlightLeaderCancelled(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	var requests int32
+	c := newBlockingClient(started, release, &requests)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	leader := make(chan error)
+	go func() {
+		_, err := c.WithContext(ctx).Get("users", "bob")
+		leader <- err
+	}()
+	<-started
+
+	follower := make(chan error)
+	go func() {
+		result, err := c.Get("users", "bob")
+		if err == nil && string(result.RawValue) != `{"name":"bob"}` {
+			err = fmt.Errorf("unexpected value %s", result.RawValue)
+		}
+		follower <- err
+	}()
+
+	// Give the follower time to join the leader's request.
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	if err := <-leader; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the leader to be cancelled, got %v", err)
+	}
+	if err := <-follower; err != nil {
+		t.Errorf("expected the follower to retry with its own context, got %v", err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("expected the follower to make its own request, got %d requests", n)
+	}
+}
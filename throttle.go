@@ -0,0 +1,55 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// Limit the number of requests the client will have in flight at once to n,
+// across every goroutine using it and every Client derived from it afterwards
+// with WithAuthToken or WithHeaders. Further requests wait for one in flight
+// to finish, which is when its response body is closed, or until their context
+// is done. A stream such as GetEventsStream holds its slot until it ends, so
+// its callback should not make requests of its own when n is one. Setting n
+// to zero, the default, removes the limit.
+//
+// This should be called before the Client is shared between goroutines.
+func (c *Client) SetMaxConcurrency(n int) {
+	if n <= 0 {
+		c.inFlight = nil
+		return
+	}
+	c.inFlight = make(chan struct{}, n)
+}
+
+// Waits until another request may be sent or ctx is done, returning a
+// function that must be called once the request has finished.
+func (c *Client) acquire(ctx context.Context) (func(), error) {
+	inFlight := c.inFlight
+	if inFlight == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case inFlight <- struct{}{}:
+		return func() { <-inFlight }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// A response body that releases its request's concurrency token when closed.
+type releasingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
@@ -0,0 +1,70 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSetMaxConcurrency(t *testing.T) {
+	var mu sync.Mutex
+	inFlight, peak := 0, 0
+
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		if inFlight > peak {
+			peak = inFlight
+		}
+		mu.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	}))
+	c.SetMaxConcurrency(2)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.Exists("users", "bob"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if peak > 2 {
+		t.Errorf("expected at most 2 requests in flight, saw %d", peak)
+	}
+	if len(c.inFlight) != 0 {
+		t.Errorf("expected every token to be released, %d held", len(c.inFlight))
+	}
+}
+
+func TestMaxConcurrencyTimeout(t *testing.T) {
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+	}))
+	c.SetMaxConcurrency(1)
+
+	held, err := c.doRequest("GET", "users/bob", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer held.Body.Close()
+
+	c.SetDefaultTimeout(50 * time.Millisecond)
+	if _, err := c.Exists("users", "alice"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the queued request to time out, got %v", err)
+	}
+}
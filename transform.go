@@ -0,0 +1,53 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+)
+
+// A transformation of the raw JSON of a stored value, such as encrypting or
+// decrypting some of its fields.
+type ValueTransform func(value []byte) ([]byte, error)
+
+// Apply outbound to the JSON of every value stored with the Put family of
+// methods, after it has been encoded and before it is sent, and inbound to
+// the JSON of every value read with Get, GetPath or the List family, as soon
+// as it is received and before it is cached or returned. Either may be nil.
+// An error from a transform fails the operation it was applied in and is
+// returned as is. Values sent by Put are buffered in full so they can be
+// transformed, including those written by PutStream. Events, relations,
+// search results and values read with GetStream are not transformed.
+//
+// This should be called before the Client is shared between goroutines.
+func (c *Client) SetValueTransforms(outbound, inbound ValueTransform) {
+	c.outbound = outbound
+	c.inbound = inbound
+}
+
+// Applies the outbound transform, if any, to a value about to be stored.
+func (c *Client) transformOutbound(value io.Reader) (io.Reader, error) {
+	if c.outbound == nil {
+		return value, nil
+	}
+
+	data, err := ioutil.ReadAll(value)
+	if err != nil {
+		return nil, err
+	}
+
+	if data, err = c.outbound(data); err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
+
+// Applies the inbound transform, if any, to a value that has been read.
+func (c *Client) transformInbound(value []byte) ([]byte, error) {
+	if c.inbound == nil || len(value) == 0 {
+		return value, nil
+	}
+	return c.inbound(value)
+}
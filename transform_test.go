@@ -0,0 +1,68 @@
+// Copyright 2014, Orchestrate.IO, Inc.
+
+package gorc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+func TestValueTransforms(t *testing.T) {
+	stored := ""
+	c := newTestClient(serve(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "PUT":
+			body, _ := ioutil.ReadAll(r.Body)
+			stored = string(body)
+			w.Header().Set("Location", "/v0/users/bob/refs/0eb4e5dca3a2ec1b")
+			w.WriteHeader(201)
+		case "GET":
+			if r.URL.Path == "/v0/users" {
+				fmt.Fprintf(w, `{"count":1,"results":[{"path":{"collection":"users","key":"bob"},"value":%s}]}`, stored)
+				return
+			}
+			w.Header().Set("ETag", `"0eb4e5dca3a2ec1b"`)
+			fmt.Fprint(w, stored)
+		}
+	}))
+
+	// Swap the field name in and out as a stand in for encryption.
+	c.SetValueTransforms(func(value []byte) ([]byte, error) {
+		return bytes.Replace(value, []byte(`"secret"`), []byte(`"sealed"`), -1), nil
+	}, func(value []byte) ([]byte, error) {
+		return bytes.Replace(value, []byte(`"sealed"`), []byte(`"secret"`), -1), nil
+	})
+
+	if _, err := c.Put("users", "bob", map[string]string{"secret": "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if stored != `{"sealed":"x"}`+"\n" {
+		t.Errorf("expected the outbound transform to be applied, stored %q", stored)
+	}
+
+	result, err := c.Get("users", "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(result.RawValue) != `{"secret":"x"}`+"\n" {
+		t.Errorf("expected the inbound transform to be applied, got %q", result.RawValue)
+	}
+
+	results, err := c.List("users", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(results.Results[0].RawValue) != `{"secret":"x"}` {
+		t.Errorf("expected listed values to be transformed, got %q", results.Results[0].RawValue)
+	}
+
+	failure := errors.New("no key")
+	c.SetValueTransforms(func([]byte) ([]byte, error) { return nil, failure }, nil)
+	if _, err := c.Put("users", "bob", map[string]string{}); err != failure {
+		t.Errorf("expected the transform's error, got %v", err)
+	}
+}